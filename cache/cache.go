@@ -0,0 +1,174 @@
+// Package cache stores the protocol files fetched from GitLab on disk, under
+// $XDG_CACHE_HOME/wlpv, so that they are shown without fetching them again
+// and remain available when fetching fails.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+	"wlpv/gitlab"
+	"wlpv/xmlparser"
+)
+
+type File struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`        // raw XML
+	ETag    string `json:"etag,omitempty"` // for conditional requests
+}
+
+// Entry is what was fetched for a UrlConfig, the fields of which are kept
+// to make the cache files readable.
+type Entry struct {
+	Origin    string    `json:"origin"`
+	Project   string    `json:"project"`
+	Branch    string    `json:"branch"`
+	Path      string    `json:"path"`
+	Commit    string    `json:"commit,omitempty"` // sha of the commit the files were read at, if known
+	FetchedAt time.Time `json:"fetched_at"`
+	Files     []File    `json:"files"`
+}
+
+// NewEntry returns the entry of a successful fetch.
+func NewEntry(uc gitlab.UrlConfig, result gitlab.FetchResult) Entry {
+	entry := Entry{
+		Origin:    uc.Origin,
+		Project:   uc.Namespace + "/" + uc.Repository,
+		Branch:    uc.Branch,
+		Path:      uc.Path,
+		Commit:    result.Commit,
+		FetchedAt: time.Now(),
+		Files:     make([]File, len(result.Files)),
+	}
+
+	for i, file := range result.Files {
+		entry.Files[i] = File{Path: file.Path, Content: string(file.Content), ETag: file.ETag}
+	}
+
+	return entry
+}
+
+// Result returns the fetch the entry was made of, without protocols, to be
+// passed to gitlab.UrlConfig.Fetch as the previous fetch.
+func (e Entry) Result() gitlab.FetchResult {
+	result := gitlab.FetchResult{
+		Commit: e.Commit,
+		Files:  make([]gitlab.File, len(e.Files)),
+	}
+
+	for i, file := range e.Files {
+		result.Files[i] = gitlab.File{Path: file.Path, Content: []byte(file.Content), ETag: file.ETag}
+	}
+
+	return result
+}
+
+// Protocols parses the files of the entry, files that fail to parse are
+// skipped and returned as errors, without a url as they were not requested.
+func (e Entry) Protocols() ([]xmlparser.Protocol, []*gitlab.FetchError) {
+	var (
+		protocols []xmlparser.Protocol
+		errs      []*gitlab.FetchError
+	)
+
+	for _, file := range e.Files {
+		protocol, err := xmlparser.ParseProtocolFile(file.Path, []byte(file.Content))
+		if err != nil {
+			errs = append(errs, &gitlab.FetchError{Path: file.Path, Err: err})
+			continue
+		}
+
+		protocols = append(protocols, protocol)
+	}
+
+	return protocols, errs
+}
+
+// Cache is a directory of entries, which are refreshed once older than TTL.
+type Cache struct {
+	Dir string
+	TTL time.Duration
+}
+
+// Open returns the cache of the user, the directory is created when the
+// first entry is stored.
+func Open(ttl time.Duration) (*Cache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache{Dir: filepath.Join(dir, "wlpv"), TTL: ttl}, nil
+}
+
+// file returns the file of the entry of uc, named after a hash of all that
+// identifies what is fetched.
+func (c *Cache) file(uc gitlab.UrlConfig) string {
+	key := fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%s\x00%d", uc.Origin, uc.Namespace, uc.Repository, uc.Branch, uc.Path, uc.UrlType)
+	sum := sha256.Sum256([]byte(key))
+
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// Load returns the entry of uc. An entry that cannot be read is treated as
+// missing, a nil cache has no entries.
+func (c *Cache) Load(uc gitlab.UrlConfig) (Entry, bool) {
+	if c == nil {
+		return Entry{}, false
+	}
+
+	data, err := os.ReadFile(c.file(uc))
+	if err != nil {
+		return Entry{}, false
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Entry{}, false
+	}
+
+	return entry, true
+}
+
+// Stale reports whether the entry should be refreshed.
+func (c *Cache) Stale(entry Entry) bool {
+	return time.Since(entry.FetchedAt) > c.TTL
+}
+
+// Store writes the entry of uc, replacing the previous one at once so that
+// a concurrent Load never reads a partial entry. A nil cache stores nothing.
+func (c *Cache) Store(uc gitlab.UrlConfig, entry Entry) error {
+	if c == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.Dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.file(uc))
+}
@@ -1,8 +1,10 @@
 package cli
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,19 +13,61 @@ import (
 )
 
 const help = `usage: wlpv [options] [protocol name]
+       wlpv <command> [options] [arguments]
 
     -h -help       Print this help message and exit.
     -v -version    Print the version number and exit.
     -a -add <path> Additional xml protocol file.
     -offline       Search for protocols found in /usr/share/* instead of fetching from git.
+
+Protocols are loaded from the sources of $XDG_CONFIG_HOME/wlpv/config.toml,
+run 'wlpv config -h' for details.
+
+commands:
+    validate       Check protocol files for mistakes.
+    export         Write documentation for protocols.
+    serve          Serve documentation and a JSON API over HTTP.
+    dump           Print protocols as JSON.
+    trace          Decode a WAYLAND_DEBUG log.
+    decode         Decode a captured stream of the wire protocol.
+    gen            Generate bindings for protocols.
+    diff           Compare two versions of protocols.
+    compat         Check that a new version of protocols is backward compatible.
+    config         Print the configured protocol sources.
+
+Run 'wlpv <command> -h' for help on a command.
 `
 
+const (
+	CommandValidate = "validate"
+	CommandExport   = "export"
+	CommandServe    = "serve"
+	CommandDump     = "dump"
+	CommandTrace    = "trace"
+	CommandDecode   = "decode"
+	CommandGen      = "gen"
+	CommandDiff     = "diff"
+	CommandCompat   = "compat"
+	CommandConfig   = "config"
+)
+
 type Options struct {
 	Help      bool                 // print help and exit(0)
 	Version   bool                 // print version and exit(0)
 	Offline   bool                 // offline mode
 	Additions []xmlparser.Protocol // additional protocols
 	Protocol  string               // name of protocol to directly open
+	Command   string               // subcommand to run instead of the viewer
+	Validate  ValidateOptions      // options of the validate command
+	Export    ExportOptions        // options of the export command
+	Serve     ServeOptions         // options of the serve command
+	Dump      DumpOptions          // options of the dump command
+	Trace     TraceOptions         // options of the trace command
+	Decode    DecodeOptions        // options of the decode command
+	Gen       GenOptions           // options of the gen command
+	Diff      DiffOptions          // options of the diff command
+	Compat    CompatOptions        // options of the compat command
+	Config    ConfigOptions        // options of the config command
 }
 
 type paths []string
@@ -42,6 +86,71 @@ func ParseArguments() (Options, error) {
 		fmt.Print(help)
 	}
 
+	if len(os.Args) > 1 {
+		var opts Options
+		var err error
+
+		switch os.Args[1] {
+		case CommandValidate:
+			opts.Command = CommandValidate
+			opts.Validate, err = parseValidateArguments(os.Args[2:])
+		case CommandExport:
+			opts.Command = CommandExport
+			opts.Export, err = parseExportArguments(os.Args[2:])
+		case CommandServe:
+			opts.Command = CommandServe
+			opts.Serve, err = parseServeArguments(os.Args[2:])
+		case CommandDump:
+			opts.Command = CommandDump
+			opts.Dump, err = parseDumpArguments(os.Args[2:])
+		case CommandTrace:
+			opts.Command = CommandTrace
+			opts.Trace, err = parseTraceArguments(os.Args[2:])
+		case CommandDecode:
+			opts.Command = CommandDecode
+			opts.Decode, err = parseDecodeArguments(os.Args[2:])
+		case CommandGen:
+			opts.Command = CommandGen
+			opts.Gen, err = parseGenArguments(os.Args[2:])
+		case CommandDiff:
+			opts.Command = CommandDiff
+			opts.Diff, err = parseDiffArguments(os.Args[2:])
+		case CommandCompat:
+			opts.Command = CommandCompat
+			opts.Compat, err = parseCompatArguments(os.Args[2:])
+		case CommandConfig:
+			opts.Command = CommandConfig
+			opts.Config, err = parseConfigArguments(os.Args[2:])
+		default:
+			return parseViewerArguments()
+		}
+
+		if errors.Is(err, flag.ErrHelp) {
+			opts.Help = true
+			err = nil
+		}
+
+		return opts, err
+	}
+
+	return parseViewerArguments()
+}
+
+// newFlagSet returns a flag set for a subcommand, whose help text replaces
+// flag.Usage so that main prints it for -h.
+func newFlagSet(name string, usage string) *flag.FlagSet {
+	flag.Usage = func() {
+		fmt.Print(usage)
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {}
+	fs.SetOutput(io.Discard)
+
+	return fs
+}
+
+func parseViewerArguments() (Options, error) {
 	shortHelpFlag := flag.Bool("h", false, "")
 	longHelpFlag := flag.Bool("help", false, "")
 
@@ -67,15 +176,11 @@ func ParseArguments() (Options, error) {
 		return opts, err
 	}
 
-	contents, err := util.ReadAllFiles(filePaths)
+	opts.Additions, err = readProtocols(filePaths)
 	if err != nil {
 		return opts, err
 	}
 
-	for _, content := range contents {
-		opts.Additions = append(opts.Additions, xmlparser.ParseProtocol(content))
-	}
-
 	opts.Protocol = flag.Arg(0)
 
 	return opts, nil
@@ -113,3 +218,23 @@ func getFilePaths(paths []string) ([]string, error) {
 
 	return filePaths, nil
 }
+
+func readProtocols(filePaths []string) ([]xmlparser.Protocol, error) {
+	var protocols []xmlparser.Protocol
+
+	for _, filePath := range filePaths {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		protocol, err := xmlparser.ParseProtocolFile(filePath, content)
+		if err != nil {
+			return nil, err
+		}
+
+		protocols = append(protocols, protocol)
+	}
+
+	return protocols, nil
+}
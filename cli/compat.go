@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+)
+
+const compatHelp = `usage: wlpv compat [options] <old xml file> <new xml file>
+       wlpv compat [options] -namespace <namespace> <old ref> <new ref>
+
+Check that the new version of a protocol file, or of the protocols of a
+namespace, is backward compatible with the old one. Exits with status 1 if
+a breaking change is found: messages removed or reordered, argument counts
+or types changed, enum entries renumbered, members added without bumping the
+interface version or with a since outside of the new versions.
+
+    -h -help                 Print this help message and exit.
+    -f -format <format>      Output format, either text or json. Defaults to text.
+    -n -namespace <name>     Fetch the protocols of this namespace (core, stable, ...) at the given refs.
+`
+
+type CompatOptions struct {
+	Old       string // old protocol file, or ref of Namespace
+	New       string // new protocol file, or ref of Namespace
+	Namespace string // namespace to fetch at two refs, files are compared if empty
+	Format    string // "text" or "json"
+}
+
+func parseCompatArguments(args []string) (CompatOptions, error) {
+	fs := newFlagSet(CommandCompat, compatHelp)
+
+	var format string
+	fs.StringVar(&format, "f", "text", "")
+	fs.StringVar(&format, "format", "text", "")
+
+	var namespace string
+	fs.StringVar(&namespace, "n", "", "")
+	fs.StringVar(&namespace, "namespace", "", "")
+
+	var opts CompatOptions
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	if fs.NArg() != 2 {
+		if namespace != "" {
+			return opts, errors.New("compat takes an old and a new ref")
+		}
+		return opts, errors.New("compat takes an old and a new protocol file")
+	}
+
+	if format != "text" && format != "json" {
+		return opts, fmt.Errorf("unknown output format %q", format)
+	}
+
+	opts.Old = fs.Arg(0)
+	opts.New = fs.Arg(1)
+	opts.Namespace = namespace
+	opts.Format = format
+
+	return opts, nil
+}
@@ -0,0 +1,67 @@
+package cli
+
+import "errors"
+
+const configHelp = `usage: wlpv config [options]
+
+Print the sources protocols are loaded from, in the format of the config
+file $XDG_CONFIG_HOME/wlpv/config.toml. Without a config file, these are the
+built-in sources, the output is then a starting point for one.
+
+    -h -help       Print this help message and exit.
+    -path          Only print the path of the config file.
+
+The config file declares sources in [[source]] tables, namespaces are shown
+in their order. A source of a built-in namespace replaces it, others come
+after the built-in ones unless "defaults = false" comes first. Namespaces
+listed by the order key are shown first, "User" is that of -a protocols:
+
+    order = ["User", "internal"]
+
+GitLab sources are cached in $XDG_CACHE_HOME/wlpv. Cached protocols are
+shown at once, and fetched again in the background once older than
+cache_ttl. When fetching fails, the cached protocols are kept. Requests to
+GitLab are abandoned after timeout, and at most max_requests are sent at
+once:
+
+    cache_ttl = "6h"
+    timeout = "30s"
+    max_requests = 8
+
+    [[source]]
+    namespace = "internal"
+    type = "gitlab"                      # or "dir" or "file"
+    origin = "https://gitlab.example.com"
+    project = "group/protocols"
+    branch = "main"
+    path = "protocols"                   # a directory, or a file ending in .xml
+
+    [[source]]
+    namespace = "vendor"
+    type = "dir"
+    path = "~/src/vendor-protocols"      # relative to the config file if not absolute
+`
+
+type ConfigOptions struct {
+	Path bool // only print the path of the config file
+}
+
+func parseConfigArguments(args []string) (ConfigOptions, error) {
+	fs := newFlagSet(CommandConfig, configHelp)
+
+	pathFlag := fs.Bool("path", false, "")
+
+	var opts ConfigOptions
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	if fs.NArg() != 0 {
+		return opts, errors.New("config takes no arguments")
+	}
+
+	opts.Path = *pathFlag
+
+	return opts, nil
+}
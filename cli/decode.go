@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"wlpv/xmlparser"
+)
+
+const decodeHelp = `usage: wlpv decode [options] <capture file>
+
+Decode a captured stream of the Wayland wire protocol against the loaded
+protocols. Messages are printed like WAYLAND_DEBUG=1 does, with the offset
+of the message in place of the timestamp, so the output can be given to
+wlpv trace.
+
+The capture file is the stream sent by the client, or by the compositor with
+-events. With -capture, it holds both directions as records of a direction
+byte, 0 for the client and 1 for the compositor, the length of the data in
+32 bits and the data, and the messages are decoded in the order they were
+sent.
+
+    -h -help               Print this help message and exit.
+    -events                The stream was sent by the compositor, not the client.
+    -capture               The file holds both directions in records.
+    -object <id>=<name>    Object that exists before the stream starts, wl_display is 1.
+    -a -add <path>         Additional xml protocol file.
+    -offline               Search for protocols found in /usr/share/* instead of fetching from git.
+`
+
+type DecodeOptions struct {
+	File      string               // capture file
+	Events    bool                 // the stream holds events
+	Capture   bool                 // the file holds records of both directions
+	Objects   map[uint32]string    // initial objects, by id
+	Offline   bool                 // offline mode
+	Additions []xmlparser.Protocol // additional protocols
+}
+
+type objects map[uint32]string
+
+func (o objects) String() string {
+	var s []string
+	for id, iface := range o {
+		s = append(s, fmt.Sprintf("%d=%s", id, iface))
+	}
+	return strings.Join(s, ", ")
+}
+
+func (o objects) Set(value string) error {
+	id, iface, ok := strings.Cut(value, "=")
+	if !ok || iface == "" {
+		return fmt.Errorf("expected <id>=<interface>, got %q", value)
+	}
+
+	n, err := strconv.ParseUint(id, 10, 32)
+	if err != nil || n == 0 {
+		return fmt.Errorf("invalid object id %q", id)
+	}
+
+	o[uint32(n)] = iface
+	return nil
+}
+
+func parseDecodeArguments(args []string) (DecodeOptions, error) {
+	fs := newFlagSet(CommandDecode, decodeHelp)
+
+	eventsFlag := fs.Bool("events", false, "")
+	captureFlag := fs.Bool("capture", false, "")
+
+	objects := make(objects)
+	fs.Var(objects, "object", "")
+
+	var paths paths
+	fs.Var(&paths, "a", "")
+	fs.Var(&paths, "add", "")
+
+	offlineFlag := fs.Bool("offline", false, "")
+
+	var opts DecodeOptions
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	if fs.NArg() != 1 {
+		return opts, errors.New("decode takes one capture file")
+	}
+
+	if *eventsFlag && *captureFlag {
+		return opts, errors.New("a capture holds both directions, -events does not apply")
+	}
+
+	opts.File = fs.Arg(0)
+	opts.Events = *eventsFlag
+	opts.Capture = *captureFlag
+	opts.Objects = objects
+	opts.Offline = *offlineFlag
+
+	filePaths, err := getFilePaths(paths)
+	if err != nil {
+		return opts, err
+	}
+
+	opts.Additions, err = readProtocols(filePaths)
+	if err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+)
+
+const diffHelp = `usage: wlpv diff [options] <old xml file> <new xml file>
+       wlpv diff [options] -namespace <namespace> <old ref> <new ref>
+
+Compare two versions of a protocol file, or the protocols of a namespace at
+two branches or tags of its git repository. Reported are added and removed
+elements, interface version bumps, changed argument types, deprecations and
+description edits.
+
+    -h -help                 Print this help message and exit.
+    -f -format <format>      Output format, either text or json. Defaults to text.
+    -n -namespace <name>     Fetch the protocols of this namespace (core, stable, ...) at the given refs.
+    -tui                     Browse the changes in the viewer, old and new side by side.
+`
+
+type DiffOptions struct {
+	Old       string // old protocol file, or ref of Namespace
+	New       string // new protocol file, or ref of Namespace
+	Namespace string // namespace to fetch at two refs, files are compared if empty
+	Format    string // output format
+	TUI       bool   // browse in the viewer
+}
+
+func parseDiffArguments(args []string) (DiffOptions, error) {
+	fs := newFlagSet(CommandDiff, diffHelp)
+
+	var format string
+	fs.StringVar(&format, "f", "text", "")
+	fs.StringVar(&format, "format", "text", "")
+
+	var namespace string
+	fs.StringVar(&namespace, "n", "", "")
+	fs.StringVar(&namespace, "namespace", "", "")
+
+	tuiFlag := fs.Bool("tui", false, "")
+
+	var opts DiffOptions
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	if fs.NArg() != 2 {
+		if namespace != "" {
+			return opts, errors.New("diff takes an old and a new ref")
+		}
+		return opts, errors.New("diff takes an old and a new protocol file")
+	}
+
+	switch format {
+	case "text", "json":
+	default:
+		return opts, fmt.Errorf("unknown output format %q", format)
+	}
+
+	opts.Old = fs.Arg(0)
+	opts.New = fs.Arg(1)
+	opts.Namespace = namespace
+	opts.Format = format
+	opts.TUI = *tuiFlag
+
+	return opts, nil
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"errors"
+	"wlpv/xmlparser"
+)
+
+const dumpHelp = `usage: wlpv dump [options] [namespace or protocol name]...
+
+Print the loaded protocols, or only the given namespaces and protocols, as
+JSON. When only -a files are given, just those are printed.
+
+    -h -help             Print this help message and exit.
+    -json                Print JSON, the only and default format.
+    -compact             Do not indent the output.
+    -a -add <path>       Additional xml protocol file.
+    -offline             Search for protocols found in /usr/share/* instead of fetching from git.
+`
+
+type DumpOptions struct {
+	Compact   bool                 // no indentation
+	Offline   bool                 // offline mode
+	Additions []xmlparser.Protocol // additional protocols
+	Names     []string             // namespaces and protocols to print, all if empty
+}
+
+func parseDumpArguments(args []string) (DumpOptions, error) {
+	fs := newFlagSet(CommandDump, dumpHelp)
+
+	jsonFlag := fs.Bool("json", true, "")
+	compactFlag := fs.Bool("compact", false, "")
+
+	var paths paths
+	fs.Var(&paths, "a", "")
+	fs.Var(&paths, "add", "")
+
+	offlineFlag := fs.Bool("offline", false, "")
+
+	var opts DumpOptions
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	if !*jsonFlag {
+		return opts, errors.New("json is the only output format")
+	}
+
+	opts.Compact = *compactFlag
+	opts.Offline = *offlineFlag
+	opts.Names = fs.Args()
+
+	filePaths, err := getFilePaths(paths)
+	if err != nil {
+		return opts, err
+	}
+
+	opts.Additions, err = readProtocols(filePaths)
+	if err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
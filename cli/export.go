@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"wlpv/xmlparser"
+)
+
+const exportHelp = `usage: wlpv export [options] [namespace or protocol name]...
+
+Write documentation for the loaded protocols, or only for the given
+namespaces and protocols. When only -a files are given, just those are
+exported.
+
+    -h -help             Print this help message and exit.
+    -f -format <format>  Output format, either markdown or html. Defaults to markdown.
+    -o -output <dir>     Directory to write to. Defaults to wlpv-docs.
+    -a -add <path>       Additional xml protocol file.
+    -offline             Search for protocols found in /usr/share/* instead of fetching from git.
+`
+
+type ExportOptions struct {
+	Format    string               // output format
+	Output    string               // output directory
+	Offline   bool                 // offline mode
+	Additions []xmlparser.Protocol // additional protocols
+	Names     []string             // namespaces and protocols to export, all if empty
+}
+
+func parseExportArguments(args []string) (ExportOptions, error) {
+	fs := newFlagSet(CommandExport, exportHelp)
+
+	var format string
+	fs.StringVar(&format, "f", "markdown", "")
+	fs.StringVar(&format, "format", "markdown", "")
+
+	var output string
+	fs.StringVar(&output, "o", "wlpv-docs", "")
+	fs.StringVar(&output, "output", "wlpv-docs", "")
+
+	var paths paths
+	fs.Var(&paths, "a", "")
+	fs.Var(&paths, "add", "")
+
+	offlineFlag := fs.Bool("offline", false, "")
+
+	var opts ExportOptions
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	switch format {
+	case "markdown", "md":
+		format = "markdown"
+	case "html":
+	default:
+		return opts, fmt.Errorf("unknown output format %q", format)
+	}
+
+	opts.Format = format
+	opts.Output = output
+	opts.Offline = *offlineFlag
+	opts.Names = fs.Args()
+
+	filePaths, err := getFilePaths(paths)
+	if err != nil {
+		return opts, err
+	}
+
+	opts.Additions, err = readProtocols(filePaths)
+	if err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/token"
+	"wlpv/xmlparser"
+)
+
+const genHelp = `usage: wlpv gen <language> [options] [namespace or protocol name]...
+
+Generate bindings for the loaded protocols, or only for the given namespaces
+and protocols. When only -a files are given, just those are generated.
+
+languages:
+    go                   A Go package with a type per interface, to be used
+                         with an implementation of its Conn interface.
+    c-header             A header per protocol, laid out like the ones of
+                         wayland-scanner.
+
+    -h -help             Print this help message and exit.
+    -o -output <dir>     Directory to write to. Defaults to the package name
+                         for go and to the current directory for c-header.
+    -package <name>      Name of the Go package. Defaults to wayland.
+    -side <side>         Either client or server. Defaults to client.
+    -a -add <path>       Additional xml protocol file.
+    -offline             Search for protocols found in /usr/share/* instead of fetching from git.
+`
+
+const (
+	GenLanguageGo      = "go"
+	GenLanguageCHeader = "c-header"
+)
+
+type GenOptions struct {
+	Language  string               // language to generate
+	Output    string               // output directory
+	Package   string               // name of the package
+	Server    bool                 // generate the server side
+	Offline   bool                 // offline mode
+	Additions []xmlparser.Protocol // additional protocols
+	Names     []string             // namespaces and protocols to generate, all if empty
+}
+
+func parseGenArguments(args []string) (GenOptions, error) {
+	fs := newFlagSet(CommandGen, genHelp)
+
+	var opts GenOptions
+
+	if len(args) == 0 {
+		return opts, errors.New("gen needs a language")
+	}
+
+	if args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		return opts, flag.ErrHelp
+	}
+
+	opts.Language = args[0]
+	switch opts.Language {
+	case GenLanguageGo, GenLanguageCHeader:
+	default:
+		return opts, fmt.Errorf("unknown language %q", opts.Language)
+	}
+
+	var output string
+	fs.StringVar(&output, "o", "", "")
+	fs.StringVar(&output, "output", "", "")
+
+	packageFlag := fs.String("package", "wayland", "")
+	sideFlag := fs.String("side", "client", "")
+
+	var paths paths
+	fs.Var(&paths, "a", "")
+	fs.Var(&paths, "add", "")
+
+	offlineFlag := fs.Bool("offline", false, "")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return opts, err
+	}
+
+	switch *sideFlag {
+	case "client":
+	case "server":
+		opts.Server = true
+	default:
+		return opts, fmt.Errorf("unknown side %q", *sideFlag)
+	}
+
+	if !token.IsIdentifier(*packageFlag) {
+		return opts, fmt.Errorf("invalid package name %q", *packageFlag)
+	}
+
+	opts.Package = *packageFlag
+	opts.Output = output
+	if opts.Output == "" && opts.Language == GenLanguageGo {
+		opts.Output = opts.Package
+	} else if opts.Output == "" {
+		opts.Output = "."
+	}
+	opts.Offline = *offlineFlag
+	opts.Names = fs.Args()
+
+	filePaths, err := getFilePaths(paths)
+	if err != nil {
+		return opts, err
+	}
+
+	opts.Additions, err = readProtocols(filePaths)
+	if err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,48 @@
+package cli
+
+const serveHelp = `usage: wlpv serve [options]
+
+Serve the documentation of the loaded protocols over HTTP, along with a JSON
+API under /api/protocols, /api/protocols/{name} and /api/search?q=. Files
+added with -a are parsed again when they change.
+
+    -h -help             Print this help message and exit.
+    -addr <address>      Address to listen on. Defaults to localhost:8080.
+    -a -add <path>       Additional xml protocol file.
+    -offline             Search for protocols found in /usr/share/* instead of fetching from git.
+`
+
+type ServeOptions struct {
+	Addr      string   // address to listen on
+	Offline   bool     // offline mode
+	Additions []string // additional protocol files, watched for changes
+}
+
+func parseServeArguments(args []string) (ServeOptions, error) {
+	fs := newFlagSet(CommandServe, serveHelp)
+
+	addrFlag := fs.String("addr", "localhost:8080", "")
+
+	var paths paths
+	fs.Var(&paths, "a", "")
+	fs.Var(&paths, "add", "")
+
+	offlineFlag := fs.Bool("offline", false, "")
+
+	var opts ServeOptions
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	opts.Addr = *addrFlag
+	opts.Offline = *offlineFlag
+
+	var err error
+	opts.Additions, err = getFilePaths(paths)
+	if err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
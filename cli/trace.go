@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"errors"
+	"wlpv/xmlparser"
+)
+
+const traceHelp = `usage: wlpv trace [options] [log file]
+
+Decode a WAYLAND_DEBUG=1 log, read from the file or from stdin, against the
+loaded protocols. Every message is annotated with its description and its
+arguments, enum values are decoded to entry names.
+
+    -h -help               Print this help message and exit.
+    -object <id>           Only show messages of the object with this id.
+    -interface <name>      Only show messages of this interface.
+    -tui                   Browse the decoded log in the viewer.
+    -a -add <path>         Additional xml protocol file.
+    -offline               Search for protocols found in /usr/share/* instead of fetching from git.
+`
+
+type TraceOptions struct {
+	File      string               // log file, stdin if empty or "-"
+	Object    uint32               // object to show messages of, all if 0
+	Interface string               // interface to show messages of, all if empty
+	TUI       bool                 // browse in the viewer
+	Offline   bool                 // offline mode
+	Additions []xmlparser.Protocol // additional protocols
+}
+
+func parseTraceArguments(args []string) (TraceOptions, error) {
+	fs := newFlagSet(CommandTrace, traceHelp)
+
+	objectFlag := fs.Uint("object", 0, "")
+	interfaceFlag := fs.String("interface", "", "")
+	tuiFlag := fs.Bool("tui", false, "")
+
+	var paths paths
+	fs.Var(&paths, "a", "")
+	fs.Var(&paths, "add", "")
+
+	offlineFlag := fs.Bool("offline", false, "")
+
+	var opts TraceOptions
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	if fs.NArg() > 1 {
+		return opts, errors.New("trace takes at most one log file")
+	}
+
+	if *objectFlag > 1<<32-1 {
+		return opts, errors.New("object id does not fit in 32 bits")
+	}
+
+	opts.File = fs.Arg(0)
+	opts.Object = uint32(*objectFlag)
+	opts.Interface = *interfaceFlag
+	opts.TUI = *tuiFlag
+	opts.Offline = *offlineFlag
+
+	filePaths, err := getFilePaths(paths)
+	if err != nil {
+		return opts, err
+	}
+
+	opts.Additions, err = readProtocols(filePaths)
+	if err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
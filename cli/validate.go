@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+)
+
+const validateHelp = `usage: wlpv validate [options] <path>...
+
+    -h -help             Print this help message and exit.
+    -f -format <format>  Output format, either text or json. Defaults to text.
+    -r -ref <path>       Protocol file whose interfaces may be referenced but which is not validated.
+    -strict              Treat warnings as errors.
+`
+
+type ValidateOptions struct {
+	Files      []string // protocol files to validate
+	References []string // protocol files only used to resolve references
+	Format     string   // "text" or "json"
+	Strict     bool     // fail on warnings
+}
+
+func parseValidateArguments(args []string) (ValidateOptions, error) {
+	fs := newFlagSet(CommandValidate, validateHelp)
+
+	var format string
+	fs.StringVar(&format, "f", "text", "")
+	fs.StringVar(&format, "format", "text", "")
+
+	var references paths
+	fs.Var(&references, "r", "")
+	fs.Var(&references, "ref", "")
+
+	strictFlag := fs.Bool("strict", false, "")
+
+	var opts ValidateOptions
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	if format != "text" && format != "json" {
+		return opts, fmt.Errorf("unknown output format %q", format)
+	}
+
+	if fs.NArg() == 0 {
+		return opts, errors.New("no protocol files given")
+	}
+
+	opts.Format = format
+	opts.Strict = *strictFlag
+
+	var err error
+
+	opts.Files, err = getFilePaths(fs.Args())
+	if err != nil {
+		return opts, err
+	}
+
+	if len(opts.Files) == 0 {
+		return opts, errors.New("no protocol files found")
+	}
+
+	opts.References, err = getFilePaths(references)
+	if err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"wlpv/cli"
+	"wlpv/compat"
+)
+
+type compatReport struct {
+	Issues   []compat.Issue `json:"issues"`
+	Breaking int            `json:"breaking"`
+}
+
+func runCompat(opts cli.CompatOptions) int {
+	before, after, err := loadVersions(opts.Namespace, opts.Old, opts.New)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	report := compatReport{Issues: []compat.Issue{}}
+
+	// files are compared whatever the names of their protocols
+	if opts.Namespace != "" {
+		report.Issues = append(report.Issues, compat.Protocols(before, after)...)
+	} else {
+		report.Issues = append(report.Issues, compat.Protocol(before[0], after[0])...)
+	}
+
+	report.Breaking = len(report.Issues)
+
+	switch opts.Format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+
+	default:
+		for _, issue := range report.Issues {
+			fmt.Println(issue)
+		}
+
+		fmt.Printf("%d breaking change(s)\n", report.Breaking)
+	}
+
+	if report.Breaking > 0 {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,200 @@
+// Package compat checks that a new version of a protocol keeps the
+// compatibility rules of Wayland: existing messages keep their opcode and
+// signature, enum entries keep their value, and new members come with a
+// bump of the interface version and a since of one of the new versions.
+package compat
+
+import (
+	"fmt"
+	"strconv"
+	"wlpv/diff"
+	"wlpv/xmlparser"
+)
+
+type Issue struct {
+	Protocol string `json:"protocol"`
+	Path     string `json:"path"` // dotted element path, e.g. wl_surface.attach.buffer
+	Message  string `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Path == "" {
+		return fmt.Sprintf("%s: %s", i.Protocol, i.Message)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", i.Protocol, i.Path, i.Message)
+}
+
+type checker struct {
+	protocol string
+	before   map[string]xmlparser.Interface
+	after    map[string]xmlparser.Interface
+	issues   []Issue
+}
+
+func (c *checker) errorf(element xmlparser.Element, format string, a ...any) {
+	c.issues = append(c.issues, Issue{
+		Protocol: c.protocol,
+		Path:     element.Path(),
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+func interfacesByName(p xmlparser.Protocol) map[string]xmlparser.Interface {
+	interfaces := make(map[string]xmlparser.Interface, len(p.Interfaces))
+	for _, iface := range p.Interfaces {
+		interfaces[iface.Name] = iface
+	}
+
+	return interfaces
+}
+
+// Protocols checks protocols matched by name, a protocol only found in
+// before is a breaking change.
+func Protocols(before, after []xmlparser.Protocol) []Issue {
+	afterByName := make(map[string]xmlparser.Protocol, len(after))
+	for _, p := range after {
+		afterByName[p.Name] = p
+	}
+
+	var issues []Issue
+	for _, b := range before {
+		a, ok := afterByName[b.Name]
+		if !ok {
+			issues = append(issues, Issue{Protocol: b.Name, Message: "protocol removed"})
+			continue
+		}
+
+		issues = append(issues, Protocol(b, a)...)
+	}
+
+	return issues
+}
+
+// Protocol returns the breaking changes from before to after.
+func Protocol(before, after xmlparser.Protocol) []Issue {
+	c := checker{
+		protocol: after.Name,
+		before:   interfacesByName(before),
+		after:    interfacesByName(after),
+	}
+
+	for _, change := range diff.Protocol(before, after) {
+		c.change(change)
+	}
+
+	for _, iface := range after.Interfaces {
+		if b, ok := c.before[iface.Name]; ok {
+			c.messages(iface.Name, xmlparser.ElementRequest, b.Requests, iface.Requests)
+			c.messages(iface.Name, xmlparser.ElementEvent, b.Events, iface.Events)
+		}
+	}
+
+	return c.issues
+}
+
+// version returns the version of an interface, 0 if it is not valid.
+func version(iface xmlparser.Interface) int {
+	v, err := strconv.Atoi(iface.Version)
+	if err != nil {
+		return 0
+	}
+
+	return v
+}
+
+func (c *checker) change(change diff.Change) {
+	element := change.Element
+
+	switch change.Kind {
+	case diff.Removed:
+		// arguments are compared by position in messages
+		if element.Kind != xmlparser.ElementRequestArgument && element.Kind != xmlparser.ElementEventArgument {
+			c.errorf(element, "%s removed", element.Kind)
+		}
+
+	case diff.Added:
+		switch element.Kind {
+		case xmlparser.ElementRequest, xmlparser.ElementEvent, xmlparser.ElementEnum, xmlparser.ElementEntry:
+			c.added(change)
+		}
+
+	case diff.Changed:
+		switch change.Field {
+		case "version":
+			if version(c.after[element.Interface]) < version(c.before[element.Interface]) {
+				c.errorf(element, "interface version decreased from %s to %s", change.Old, change.New)
+			}
+		case "since":
+			c.errorf(element, "since changed from %s to %s", valueOrOne(change.Old), valueOrOne(change.New))
+		case "value":
+			c.errorf(element, "entry renumbered from %s to %s", change.Old, change.New)
+		}
+	}
+}
+
+func valueOrOne(since string) string {
+	if since == "" {
+		return "1"
+	}
+
+	return since
+}
+
+// added checks that a new member comes with a version bump of its interface
+// and is available since one of the versions after the old one.
+func (c *checker) added(change diff.Change) {
+	element := change.Element
+	before := version(c.before[element.Interface])
+	after := version(c.after[element.Interface])
+
+	if after <= before {
+		c.errorf(element, "%s added without bumping the interface version from %d", element.Kind, before)
+		return
+	}
+
+	since := valueOrOne(change.Since)
+	if n, err := strconv.Atoi(since); err != nil || n <= before || n > after {
+		if after == before+1 {
+			c.errorf(element, "%s added since %s, expected since %d", element.Kind, since, after)
+		} else {
+			c.errorf(element, "%s added since %s, expected since %d to %d", element.Kind, since, before+1, after)
+		}
+	}
+}
+
+// messages checks the messages found in both versions: their opcode changes
+// when messages are removed, inserted before them or reordered, and their
+// signature when arguments are added, removed or change type. Arguments are
+// compared by position, a renamed argument is sent the same way.
+func (c *checker) messages(iface string, kind xmlparser.ElementKind, before, after []xmlparser.Message) {
+	byName := make(map[string]xmlparser.Message, len(before))
+	for _, m := range before {
+		byName[m.Name] = m
+	}
+
+	for _, m := range after {
+		b, ok := byName[m.Name]
+		if !ok {
+			continue
+		}
+
+		element := xmlparser.Element{Kind: kind, Interface: iface, Member: m.Name}
+
+		if b.Opcode != m.Opcode {
+			c.errorf(element, "%s moved from opcode %d to %d", kind, b.Opcode, m.Opcode)
+		}
+
+		if len(b.Arguments) != len(m.Arguments) {
+			c.errorf(element, "number of arguments changed from %d to %d", len(b.Arguments), len(m.Arguments))
+			continue
+		}
+
+		for i, arg := range m.Arguments {
+			old := b.Arguments[i]
+			if old.Type != arg.Type || old.Interface != arg.Interface {
+				c.errorf(element, "type of argument %d (%s) changed from %s to %s", i+1, arg.Name, old.TypeName(), arg.TypeName())
+			}
+		}
+	}
+}
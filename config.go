@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"wlpv/cli"
+	"wlpv/config"
+)
+
+func runConfig(opts cli.ConfigOptions) int {
+	path, err := config.Path()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if opts.Path {
+		fmt.Println(path)
+		return 0
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("# %s\n%s", path, cfg.TOML())
+
+	return 0
+}
@@ -0,0 +1,477 @@
+// Package config reads the sources protocols are loaded from. They are
+// declared in $XDG_CONFIG_HOME/wlpv/config.toml, a TOML file:
+//
+//	# drop the built-in sources, they are used when omitted or true
+//	defaults = false
+//	# namespaces shown first, "User" is that of the -a protocols
+//	order = ["User", "vendor"]
+//	# age after which cached GitLab sources are fetched again
+//	cache_ttl = "6h"
+//	# time after which a request to GitLab is abandoned
+//	timeout = "30s"
+//	# requests to GitLab sent at once
+//	max_requests = 8
+//
+//	[[source]]
+//	namespace = "core"
+//	type = "gitlab"
+//	origin = "https://gitlab.freedesktop.org"
+//	project = "wayland/wayland"
+//	branch = "main"
+//	path = "protocol/wayland.xml"
+//
+//	[[source]]
+//	namespace = "vendor"
+//	type = "dir"
+//	path = "~/src/vendor-protocols"
+//
+// A GitLab source fetches a file if its path ends with .xml and every
+// protocol of a directory otherwise. A "dir" source reads every protocol of
+// a local directory and a "file" source a single local protocol file,
+// relative paths are relative to the directory of the config file.
+// Namespaces are shown in the order of the order key followed by that of
+// the sources, a source of a built-in namespace replaces the built-in one.
+// The protocols of GitLab sources are cached, an entry older than cache_ttl
+// is shown and fetched again in the background.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"wlpv/gitlab"
+
+	"github.com/BurntSushi/toml"
+)
+
+type SourceType uint8
+
+const (
+	SourceGitLab SourceType = iota
+	SourceDir
+	SourceFile
+)
+
+func (t SourceType) String() string {
+	switch t {
+	case SourceGitLab:
+		return "gitlab"
+	case SourceDir:
+		return "dir"
+	case SourceFile:
+		return "file"
+	}
+
+	return ""
+}
+
+type Source struct {
+	Namespace string
+	Type      SourceType
+	GitLab    gitlab.UrlConfig // repository of a GitLab source
+	Path      string           // directory or file of a local source
+}
+
+type Config struct {
+	Order       []string      // namespaces shown first
+	Sources     []Source      // in the order their namespaces are shown after Order
+	CacheTTL    time.Duration // age after which cached protocols are refreshed
+	Timeout     time.Duration // of a request to GitLab
+	MaxRequests int           // requests to GitLab in flight at once
+}
+
+const (
+	defaultOrigin      = "https://gitlab.freedesktop.org"
+	defaultCacheTTL    = 6 * time.Hour
+	defaultTimeout     = 30 * time.Second
+	defaultMaxRequests = 8
+)
+
+// Default returns the built-in sources.
+func Default() Config {
+	gitlabSource := func(namespace string, uc gitlab.UrlConfig) Source {
+		return Source{Namespace: namespace, Type: SourceGitLab, GitLab: uc}
+	}
+
+	return Config{CacheTTL: defaultCacheTTL, Timeout: defaultTimeout, MaxRequests: defaultMaxRequests, Sources: []Source{
+		gitlabSource("core", gitlab.UrlConfig{
+			Origin:     defaultOrigin,
+			Namespace:  "wayland",
+			Repository: "wayland",
+			Branch:     "main",
+			UrlType:    gitlab.UrlTypeFiles,
+			Path:       "protocol/wayland.xml",
+		}),
+		gitlabSource("stable", gitlab.UrlConfig{
+			Origin:     defaultOrigin,
+			Namespace:  "wayland",
+			Repository: "wayland-protocols",
+			Branch:     "main",
+			UrlType:    gitlab.UrlTypeTree,
+			Path:       "stable",
+		}),
+		gitlabSource("staging", gitlab.UrlConfig{
+			Origin:     defaultOrigin,
+			Namespace:  "wayland",
+			Repository: "wayland-protocols",
+			Branch:     "main",
+			UrlType:    gitlab.UrlTypeTree,
+			Path:       "staging",
+		}),
+		gitlabSource("unstable", gitlab.UrlConfig{
+			Origin:     defaultOrigin,
+			Namespace:  "wayland",
+			Repository: "wayland-protocols",
+			Branch:     "main",
+			UrlType:    gitlab.UrlTypeTree,
+			Path:       "unstable",
+		}),
+		gitlabSource("wlroots", gitlab.UrlConfig{
+			Origin:     defaultOrigin,
+			Namespace:  "wlroots",
+			Repository: "wlr-protocols",
+			Branch:     "master",
+			UrlType:    gitlab.UrlTypeTree,
+			Path:       "unstable",
+		}),
+		gitlabSource("weston", gitlab.UrlConfig{
+			Origin:     defaultOrigin,
+			Namespace:  "wayland",
+			Repository: "weston",
+			Branch:     "main",
+			UrlType:    gitlab.UrlTypeTree,
+			Path:       "protocol",
+		}),
+		gitlabSource("kde", gitlab.UrlConfig{
+			Origin:     "https://invent.kde.org",
+			Namespace:  "libraries",
+			Repository: "plasma-wayland-protocols",
+			Branch:     "master",
+			UrlType:    gitlab.UrlTypeTree,
+			Path:       "src/protocols",
+		}),
+	}}
+}
+
+// Namespaces returns the namespaces of Order followed by the other
+// namespaces of the sources, in the order they are shown.
+func (c Config) Namespaces() []string {
+	namespaces := append([]string(nil), c.Order...)
+
+	for _, source := range c.Sources {
+		if !slices.Contains(namespaces, source.Namespace) {
+			namespaces = append(namespaces, source.Namespace)
+		}
+	}
+
+	return namespaces
+}
+
+// Source returns the source of a namespace.
+func (c Config) Source(namespace string) (Source, bool) {
+	for _, source := range c.Sources {
+		if source.Namespace == namespace {
+			return source, true
+		}
+	}
+
+	return Source{}, false
+}
+
+// Path returns the path of the config file.
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "wlpv", "config.toml"), nil
+}
+
+// Load reads the config file, the built-in sources are returned if there is
+// none.
+func Load() (Config, error) {
+	path, err := Path()
+	if err != nil {
+		return Default(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Default(), nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+
+	return Parse(path, data)
+}
+
+// Parse parses the content of the config file at path, relative paths of
+// local sources are resolved against its directory.
+func Parse(path string, data []byte) (Config, error) {
+	var f file
+
+	md, err := toml.Decode(string(data), &f)
+	if err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return Config{}, fmt.Errorf("%s:%d: %s", path, parseErr.Position.Line, parseErr.Message)
+		}
+
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return Config{}, fmt.Errorf("%s: unknown key %s", path, undecoded[0])
+	}
+
+	// each [[source]] is a key of its own, followed by those it sets
+	i := -1
+	for _, key := range md.Keys() {
+		switch {
+		case key[0] != "source":
+		case len(key) == 1:
+			i++
+		default:
+			f.Sources[i].keys = append(f.Sources[i].keys, key[1])
+		}
+	}
+
+	c := Config{CacheTTL: defaultCacheTTL, Timeout: defaultTimeout, MaxRequests: defaultMaxRequests}
+	if f.Defaults == nil || *f.Defaults {
+		c = Default()
+	}
+
+	c.Order = f.Order
+	if f.CacheTTL != nil {
+		if *f.CacheTTL < 0 {
+			return Config{}, fmt.Errorf("%s: cache_ttl must not be negative", path)
+		}
+
+		c.CacheTTL = time.Duration(*f.CacheTTL)
+	}
+	if f.Timeout != nil {
+		if *f.Timeout <= 0 {
+			return Config{}, fmt.Errorf("%s: timeout must be positive", path)
+		}
+
+		c.Timeout = time.Duration(*f.Timeout)
+	}
+	if f.MaxRequests != nil {
+		if *f.MaxRequests < 1 {
+			return Config{}, fmt.Errorf("%s: max_requests must be a positive integer", path)
+		}
+
+		c.MaxRequests = *f.MaxRequests
+	}
+
+	seen := make(map[string]bool)
+	for i, fs := range f.Sources {
+		source, err := fs.source(filepath.Dir(path))
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: source %d: %w", path, i+1, err)
+		}
+
+		if seen[source.Namespace] {
+			return Config{}, fmt.Errorf("%s: source %d: duplicate namespace %q", path, i+1, source.Namespace)
+		}
+		seen[source.Namespace] = true
+
+		replaced := false
+		for i := range c.Sources {
+			if c.Sources[i].Namespace == source.Namespace {
+				c.Sources[i] = source
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			c.Sources = append(c.Sources, source)
+		}
+	}
+
+	return c, nil
+}
+
+// file is the config file as decoded, the keys it does not set are nil.
+type file struct {
+	Defaults    *bool        `toml:"defaults"`
+	Order       []string     `toml:"order,omitempty"`
+	CacheTTL    *duration    `toml:"cache_ttl"`
+	Timeout     *duration    `toml:"timeout"`
+	MaxRequests *int         `toml:"max_requests"`
+	Sources     []fileSource `toml:"source"`
+}
+
+// fileSource is a [[source]] table, keys are those it sets.
+type fileSource struct {
+	Namespace string `toml:"namespace"`
+	Type      string `toml:"type"`
+	Origin    string `toml:"origin,omitempty"`
+	Project   string `toml:"project,omitempty"`
+	Branch    string `toml:"branch,omitempty"`
+	Path      string `toml:"path"`
+
+	keys []string
+}
+
+// duration is a time.Duration written like "6h".
+type duration time.Duration
+
+func (d duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = duration(v)
+
+	return nil
+}
+
+func (fs fileSource) source(dir string) (Source, error) {
+	source := Source{Namespace: fs.Namespace}
+	if source.Namespace == "" {
+		return source, errors.New("source without namespace")
+	}
+
+	only := func(keys ...string) error {
+		allowed := map[string]bool{"namespace": true, "type": true}
+		for _, key := range keys {
+			allowed[key] = true
+		}
+
+		for _, key := range fs.keys {
+			if !allowed[key] {
+				return fmt.Errorf("%s is not used by %s sources", key, fs.Type)
+			}
+		}
+
+		if fs.Path == "" {
+			return errors.New("source without path")
+		}
+
+		return nil
+	}
+
+	switch fs.Type {
+	case "gitlab":
+		if err := only("origin", "project", "branch", "path"); err != nil {
+			return source, err
+		}
+
+		slash := strings.LastIndexByte(fs.Project, '/')
+		if slash <= 0 || slash == len(fs.Project)-1 {
+			return source, fmt.Errorf("project %q is not of the form group/repository", fs.Project)
+		}
+
+		source.Type = SourceGitLab
+		source.GitLab = gitlab.UrlConfig{
+			Origin:     strings.TrimSuffix(fs.Origin, "/"),
+			Namespace:  fs.Project[:slash],
+			Repository: fs.Project[slash+1:],
+			Branch:     fs.Branch,
+			UrlType:    gitlab.UrlTypeTree,
+			Path:       strings.Trim(fs.Path, "/"),
+		}
+
+		if source.GitLab.Origin == "" {
+			source.GitLab.Origin = defaultOrigin
+		}
+		if source.GitLab.Branch == "" {
+			source.GitLab.Branch = "main"
+		}
+		if strings.HasSuffix(source.GitLab.Path, ".xml") {
+			source.GitLab.UrlType = gitlab.UrlTypeFiles
+		}
+
+	case "dir", "file":
+		if err := only("path"); err != nil {
+			return source, err
+		}
+
+		source.Type = SourceDir
+		if fs.Type == "file" {
+			source.Type = SourceFile
+		}
+		source.Path = resolvePath(dir, fs.Path)
+
+	case "":
+		return source, errors.New("source without type")
+
+	default:
+		return source, fmt.Errorf("unknown source type %q, expected gitlab, dir or file", fs.Type)
+	}
+
+	return source, nil
+}
+
+// resolvePath expands a leading ~ to the home directory and makes a
+// relative path relative to dir.
+func resolvePath(dir string, path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	return path
+}
+
+// TOML returns the config in the format of the config file.
+func (c Config) TOML() string {
+	defaults := false
+	cacheTTL := duration(c.CacheTTL)
+	timeout := duration(c.Timeout)
+
+	f := file{
+		Defaults:    &defaults,
+		Order:       c.Order,
+		CacheTTL:    &cacheTTL,
+		Timeout:     &timeout,
+		MaxRequests: &c.MaxRequests,
+	}
+
+	for _, source := range c.Sources {
+		fs := fileSource{Namespace: source.Namespace, Type: source.Type.String()}
+
+		switch source.Type {
+		case SourceGitLab:
+			uc := source.GitLab
+			fs.Origin = uc.Origin
+			fs.Project = uc.Namespace + "/" + uc.Repository
+			fs.Branch = uc.Branch
+			fs.Path = uc.Path
+		case SourceDir, SourceFile:
+			fs.Path = source.Path
+		}
+
+		f.Sources = append(f.Sources, fs)
+	}
+
+	var sb strings.Builder
+
+	enc := toml.NewEncoder(&sb)
+	enc.Indent = ""
+
+	// the file only holds values toml can encode
+	if err := enc.Encode(f); err != nil {
+		panic(err)
+	}
+
+	return sb.String()
+}
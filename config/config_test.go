@@ -0,0 +1,159 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"wlpv/gitlab"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Config
+	}{
+		{
+			name: "sources",
+			data: `
+defaults = false
+order = ["User", "vendor"]
+cache_ttl = "1h"
+timeout = "5s"
+max_requests = 2
+
+[[source]]
+namespace = "core"
+type = "gitlab"
+project = "wayland/wayland"
+path = "protocol/wayland.xml"
+
+[[source]]
+namespace = "vendor"
+type = "dir"
+path = "protocols"
+`,
+			want: Config{
+				Order:       []string{"User", "vendor"},
+				CacheTTL:    time.Hour,
+				Timeout:     5 * time.Second,
+				MaxRequests: 2,
+				Sources: []Source{
+					{Namespace: "core", Type: SourceGitLab, GitLab: gitlab.UrlConfig{
+						Origin:     defaultOrigin,
+						Namespace:  "wayland",
+						Repository: "wayland",
+						Branch:     "main",
+						Path:       "protocol/wayland.xml",
+						UrlType:    gitlab.UrlTypeFiles,
+					}},
+					{Namespace: "vendor", Type: SourceDir, Path: "/etc/wlpv/protocols"},
+				},
+			},
+		},
+		{
+			name: "strings",
+			data: `
+defaults = false
+
+[[source]]
+namespace = "a#b" # a comment
+type = 'file'
+path = "tab\there\u00e9.xml"
+
+[[source]]
+namespace = """multi
+line"""
+type = "dir"
+path = 'C:\protocols'
+`,
+			want: Config{
+				CacheTTL:    defaultCacheTTL,
+				Timeout:     defaultTimeout,
+				MaxRequests: defaultMaxRequests,
+				Sources: []Source{
+					{Namespace: "a#b", Type: SourceFile, Path: "/etc/wlpv/tab\thereé.xml"},
+					{Namespace: "multi\nline", Type: SourceDir, Path: `/etc/wlpv/C:\protocols`},
+				},
+			},
+		},
+		{
+			name: "multiline array",
+			data: `
+defaults = false
+order = [
+	"b", # a comment
+	"a",
+]
+`,
+			want: Config{
+				Order:       []string{"b", "a"},
+				CacheTTL:    defaultCacheTTL,
+				Timeout:     defaultTimeout,
+				MaxRequests: defaultMaxRequests,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Parse("/etc/wlpv/config.toml", []byte(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"go escape", "[[source]]\nnamespace = \"\\x41\"", "config.toml:2: "},
+		{"unterminated string", "order = [\"a]\n", "config.toml:1: "},
+		{"duplicate key", "[[source]]\nnamespace = \"a\"\nnamespace = \"b\"\n", "config.toml:3: "},
+		{"wrong type", "defaults = 1\n", "config.toml: "},
+		{"invalid duration", "\ncache_ttl = \"6x\"\n", "config.toml:2: "},
+		{"negative timeout", "timeout = \"-1s\"\n", "config.toml: timeout must be positive"},
+		{"unknown key", "colour = true\n", "config.toml: unknown key colour"},
+		{"unknown table", "[sources]\nnamespace = \"a\"\n", "config.toml: unknown key sources"},
+		{"unknown source key", "[[source]]\nnamespace = \"a\"\ncolour = true\n", "config.toml: unknown key source.colour"},
+		{"unused source key", "[[source]]\nnamespace = \"a\"\ntype = \"dir\"\npath = \"p\"\nbranch = \"main\"\n", "config.toml: source 1: branch is not used by dir sources"},
+		{"duplicate namespace", "[[source]]\nnamespace = \"a\"\ntype = \"dir\"\npath = \"p\"\n[[source]]\nnamespace = \"a\"\ntype = \"dir\"\npath = \"q\"\n", "config.toml: source 2: duplicate namespace \"a\""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse("config.toml", []byte(test.data))
+			if err == nil {
+				t.Fatalf("got no error, want %q", test.want)
+			}
+
+			if !strings.HasPrefix(err.Error(), test.want) {
+				t.Errorf("got error %q, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestTOML(t *testing.T) {
+	want := Default()
+	want.Order = []string{"User"}
+	want.Sources = append(want.Sources, Source{Namespace: "vendor \"quoted\"", Type: SourceDir, Path: "/src/vendor\tprotocols"})
+
+	got, err := Parse("/config.toml", []byte(want.TOML()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"wlpv/cli"
+	"wlpv/wire"
+)
+
+func runDecode(opts cli.DecodeOptions) int {
+	protocols, _, err := loadProtocols(opts.Offline, opts.Additions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	data, err := os.ReadFile(opts.File)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	decoder := wire.NewDecoder(protocols)
+	for id, iface := range opts.Objects {
+		if !decoder.SetObject(id, iface) {
+			fmt.Fprintf(os.Stderr, "warning: unknown interface %s\n", iface)
+		}
+	}
+
+	var messages []wire.Message
+
+	switch {
+	case opts.Capture:
+		var records []wire.Record
+		records, err = wire.ReadCapture(data, decoder.ByteOrder)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s: %v\n", opts.File, err)
+			return 1
+		}
+
+		messages, err = decoder.DecodeRecords(records)
+	case opts.Events:
+		messages, err = decoder.Decode(data, wire.ServerToClient)
+	default:
+		messages, err = decoder.Decode(data, wire.ClientToServer)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	for _, message := range messages {
+		// like WAYLAND_DEBUG, from the side of the client
+		arrow := " -> "
+		if message.Direction == wire.ServerToClient {
+			arrow = ""
+		}
+
+		fmt.Fprintf(w, "[%10d] %s%s\n", message.Offset, arrow, message)
+	}
+	w.Flush()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s: %v\n", opts.File, err)
+		return 1
+	}
+
+	return 0
+}
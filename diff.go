@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"wlpv/cache"
+	"wlpv/cli"
+	"wlpv/config"
+	"wlpv/diff"
+	"wlpv/gitlab"
+	"wlpv/inet"
+	"wlpv/tui"
+	"wlpv/xmlparser"
+)
+
+type diffChange struct {
+	Kind     string `json:"kind"`
+	Protocol string `json:"protocol"`
+	Element  string `json:"element"`
+	Path     string `json:"path,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Since    string `json:"since,omitempty"`
+	Old      string `json:"old,omitempty"`
+	New      string `json:"new,omitempty"`
+}
+
+// loadVersions returns the protocols of the namespace at the two refs, or
+// the protocols of the two files if namespace is empty.
+func loadVersions(namespace string, oldVersion string, newVersion string) ([]xmlparser.Protocol, []xmlparser.Protocol, error) {
+	if namespace != "" {
+		cfg, err := loadConfig()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		source, ok := cfg.Source(namespace)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown namespace %q", namespace)
+		}
+
+		if source.Type != config.SourceGitLab {
+			return nil, nil, fmt.Errorf("namespace %q is not fetched from GitLab", namespace)
+		}
+
+		c, _ := cache.Open(cfg.CacheTTL)
+		client := gitlab.NewClient(cfg.Timeout, cfg.MaxRequests)
+
+		// ^C aborts fetching
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		before, err := inet.GetNamespaceContents(ctx, client, namespace, source.GitLab, oldVersion, c)
+		if ctx.Err() != nil {
+			return nil, nil, errInterrupted
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		after, err := inet.GetNamespaceContents(ctx, client, namespace, source.GitLab, newVersion, c)
+		if ctx.Err() != nil {
+			return nil, nil, errInterrupted
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return before, after, nil
+	}
+
+	before, err := readProtocolFile(oldVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after, err := readProtocolFile(newVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []xmlparser.Protocol{before}, []xmlparser.Protocol{after}, nil
+}
+
+func runDiff(opts cli.DiffOptions) int {
+	before, after, err := loadVersions(opts.Namespace, opts.Old, opts.New)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	// files are compared whatever the names of their protocols
+	var changes []diff.Change
+	if opts.Namespace != "" {
+		changes = diff.Protocols(before, after)
+	} else {
+		changes = diff.Protocol(before[0], after[0])
+	}
+
+	if opts.TUI {
+		if err := tui.RunDiff(changes); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+
+		return 0
+	}
+
+	if opts.Format == "json" {
+		result := make([]diffChange, len(changes))
+		for i, change := range changes {
+			result[i] = diffChange{
+				Kind:     change.Kind.String(),
+				Protocol: change.Protocol,
+				Element:  change.Element.Kind.String(),
+				Path:     change.Element.Path(),
+				Field:    change.Field,
+				Since:    change.Since,
+				Old:      change.Old,
+				New:      change.New,
+			}
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(result); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+
+		return 0
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "no changes")
+		return 0
+	}
+
+	protocol := ""
+	for i, change := range changes {
+		if i == 0 || change.Protocol != protocol {
+			protocol = change.Protocol
+			fmt.Fprintln(w, protocol)
+		}
+
+		fmt.Fprintf(w, "    %s\n", change)
+	}
+
+	return 0
+}
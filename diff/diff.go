@@ -0,0 +1,343 @@
+// Package diff compares two versions of protocols element by element.
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"wlpv/xmlparser"
+)
+
+type Kind uint8
+
+const (
+	Added Kind = iota
+	Removed
+	Changed
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	}
+
+	return ""
+}
+
+// Change is a difference between two versions of a protocol. For an added
+// or removed element, New or Old is a one-line form of the element. For a
+// changed element, Field is the attribute that changed and Old and New are
+// its values.
+type Change struct {
+	Kind     Kind
+	Protocol string
+	Element  xmlparser.Element
+	Field    string // "version", "type", "since", "deprecated-since", "bitfield", "value" or "description"
+	Since    string // of an added element
+	Old      string
+	New      string
+}
+
+// Name returns the kind and the path of the changed element, like
+// "request wl_surface.attach".
+func (c Change) Name() string {
+	if c.Element.Kind == xmlparser.ElementProtocol {
+		return "protocol " + c.Protocol
+	}
+
+	return fmt.Sprintf("%s %s", c.Element.Kind, c.Element.Path())
+}
+
+// String describes the change in one line, like "added request
+// wl_surface.offset (since 5)".
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		if c.Since != "" {
+			return fmt.Sprintf("added %s (since %s)", c.Name(), c.Since)
+		}
+		return "added " + c.Name()
+	case Removed:
+		return "removed " + c.Name()
+	}
+
+	switch {
+	case c.Field == "description":
+		return fmt.Sprintf("changed description of %s", c.Name())
+	case c.Field == "deprecated-since" && c.Old == "":
+		return fmt.Sprintf("deprecated %s since %s", c.Name(), c.New)
+	}
+
+	return fmt.Sprintf("changed %s of %s from %s to %s", c.Field, c.Name(), valueOrNone(c.Old), valueOrNone(c.New))
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+
+	return value
+}
+
+// Protocols compares protocols matched by name, those only found in before
+// are removed and those only found in after are added.
+func Protocols(before, after []xmlparser.Protocol) []Change {
+	var d differ
+
+	match(before, after, func(p xmlparser.Protocol) string { return p.Name },
+		func(p xmlparser.Protocol) {
+			d.protocol = p.Name
+			d.add(Removed, xmlparser.Element{Kind: xmlparser.ElementProtocol}, "", p.Description.Summary, "")
+		},
+		func(p xmlparser.Protocol) {
+			d.protocol = p.Name
+			d.add(Added, xmlparser.Element{Kind: xmlparser.ElementProtocol}, "", "", p.Description.Summary)
+		},
+		func(b, a xmlparser.Protocol) {
+			d.protocol = a.Name
+			d.protocols(b, a)
+		},
+	)
+
+	return d.changes
+}
+
+// Protocol compares two versions of a protocol, whatever their names.
+func Protocol(before, after xmlparser.Protocol) []Change {
+	d := differ{protocol: after.Name}
+	d.protocols(before, after)
+
+	return d.changes
+}
+
+type differ struct {
+	protocol string // name of the protocol being compared
+	changes  []Change
+}
+
+func (d *differ) add(kind Kind, element xmlparser.Element, since string, oldValue string, newValue string) {
+	d.changes = append(d.changes, Change{
+		Kind:     kind,
+		Protocol: d.protocol,
+		Element:  element,
+		Since:    since,
+		Old:      oldValue,
+		New:      newValue,
+	})
+}
+
+func (d *differ) field(element xmlparser.Element, field string, oldValue string, newValue string) {
+	if oldValue == newValue {
+		return
+	}
+
+	d.changes = append(d.changes, Change{
+		Kind:     Changed,
+		Protocol: d.protocol,
+		Element:  element,
+		Field:    field,
+		Old:      oldValue,
+		New:      newValue,
+	})
+}
+
+// description records a change of a description unless only its
+// whitespace changed.
+func (d *differ) description(element xmlparser.Element, oldValue string, newValue string) {
+	if strings.Join(strings.Fields(oldValue), " ") == strings.Join(strings.Fields(newValue), " ") {
+		return
+	}
+
+	d.field(element, "description", oldValue, newValue)
+}
+
+// match calls removed for the elements only found in before, in their
+// order, then both for the elements found in both and added for those only
+// found in after, in the order of after.
+func match[T any](before, after []T, name func(T) string, removed func(T), added func(T), both func(T, T)) {
+	afterNames := make(map[string]bool, len(after))
+	for _, a := range after {
+		afterNames[name(a)] = true
+	}
+
+	beforeByName := make(map[string]T, len(before))
+	for _, b := range before {
+		beforeByName[name(b)] = b
+		if !afterNames[name(b)] {
+			removed(b)
+		}
+	}
+
+	for _, a := range after {
+		if b, ok := beforeByName[name(a)]; ok {
+			both(b, a)
+		} else {
+			added(a)
+		}
+	}
+}
+
+func descriptionText(d xmlparser.Description) string {
+	return strings.TrimSpace(d.Summary + "\n\n" + d.Text())
+}
+
+func (d *differ) protocols(before, after xmlparser.Protocol) {
+	d.description(xmlparser.Element{Kind: xmlparser.ElementProtocol}, descriptionText(before.Description), descriptionText(after.Description))
+
+	match(before.Interfaces, after.Interfaces, func(i xmlparser.Interface) string { return i.Name },
+		func(i xmlparser.Interface) {
+			d.add(Removed, interfaceElement(i), "", interfaceLine(i), "")
+		},
+		func(i xmlparser.Interface) {
+			d.add(Added, interfaceElement(i), "", "", interfaceLine(i))
+		},
+		d.interfaces,
+	)
+}
+
+func interfaceElement(i xmlparser.Interface) xmlparser.Element {
+	return xmlparser.Element{Kind: xmlparser.ElementInterface, Interface: i.Name}
+}
+
+func interfaceLine(i xmlparser.Interface) string {
+	line := fmt.Sprintf("%s version %s", i.Name, i.Version)
+	if i.Description.Summary != "" {
+		line += ": " + i.Description.Summary
+	}
+
+	return line
+}
+
+func (d *differ) interfaces(before, after xmlparser.Interface) {
+	element := interfaceElement(after)
+
+	d.field(element, "version", before.Version, after.Version)
+	d.description(element, descriptionText(before.Description), descriptionText(after.Description))
+
+	d.messages(after.Name, xmlparser.ElementRequest, before.Requests, after.Requests)
+	d.messages(after.Name, xmlparser.ElementEvent, before.Events, after.Events)
+
+	match(before.Enums, after.Enums, func(e xmlparser.Enum) string { return e.Name },
+		func(e xmlparser.Enum) {
+			d.add(Removed, enumElement(after.Name, e), "", enumLine(e), "")
+		},
+		func(e xmlparser.Enum) {
+			d.add(Added, enumElement(after.Name, e), e.Since, "", enumLine(e))
+		},
+		func(b, a xmlparser.Enum) {
+			d.enums(after.Name, b, a)
+		},
+	)
+}
+
+func messageLine(m xmlparser.Message) string {
+	args := make([]string, len(m.Arguments))
+	for i, arg := range m.Arguments {
+		args[i] = argumentLine(arg)
+	}
+
+	return fmt.Sprintf("%s(%s)", m.Name, strings.Join(args, ", "))
+}
+
+func argumentLine(a xmlparser.Argument) string {
+	return fmt.Sprintf("%s: %s", a.Name, a.TypeName())
+}
+
+func argumentText(a xmlparser.Argument) string {
+	return strings.TrimSpace(a.Summary + "\n\n" + descriptionText(a.Description))
+}
+
+func (d *differ) messages(iface string, kind xmlparser.ElementKind, before, after []xmlparser.Message) {
+	argKind := xmlparser.ElementRequestArgument
+	if kind == xmlparser.ElementEvent {
+		argKind = xmlparser.ElementEventArgument
+	}
+
+	match(before, after, func(m xmlparser.Message) string { return m.Name },
+		func(m xmlparser.Message) {
+			d.add(Removed, xmlparser.Element{Kind: kind, Interface: iface, Member: m.Name}, "", messageLine(m), "")
+		},
+		func(m xmlparser.Message) {
+			d.add(Added, xmlparser.Element{Kind: kind, Interface: iface, Member: m.Name}, m.Since, "", messageLine(m))
+		},
+		func(b, a xmlparser.Message) {
+			element := xmlparser.Element{Kind: kind, Interface: iface, Member: a.Name}
+
+			d.field(element, "type", b.Type, a.Type)
+			d.field(element, "since", b.Since, a.Since)
+			d.field(element, "deprecated-since", b.DeprecatedSince, a.DeprecatedSince)
+			d.description(element, descriptionText(b.Description), descriptionText(a.Description))
+
+			match(b.Arguments, a.Arguments, func(arg xmlparser.Argument) string { return arg.Name },
+				func(arg xmlparser.Argument) {
+					d.add(Removed, xmlparser.Element{Kind: argKind, Interface: iface, Member: a.Name, Child: arg.Name}, "", argumentLine(arg), "")
+				},
+				func(arg xmlparser.Argument) {
+					d.add(Added, xmlparser.Element{Kind: argKind, Interface: iface, Member: a.Name, Child: arg.Name}, arg.Since, "", argumentLine(arg))
+				},
+				func(bArg, aArg xmlparser.Argument) {
+					child := xmlparser.Element{Kind: argKind, Interface: iface, Member: a.Name, Child: aArg.Name}
+
+					d.field(child, "type", bArg.TypeName(), aArg.TypeName())
+					d.field(child, "since", bArg.Since, aArg.Since)
+					d.description(child, argumentText(bArg), argumentText(aArg))
+				},
+			)
+		},
+	)
+}
+
+func enumElement(iface string, e xmlparser.Enum) xmlparser.Element {
+	return xmlparser.Element{Kind: xmlparser.ElementEnum, Interface: iface, Member: e.Name}
+}
+
+func enumLine(e xmlparser.Enum) string {
+	if e.Bitfield == "true" {
+		return fmt.Sprintf("bitfield %s", e.Name)
+	}
+
+	return fmt.Sprintf("enum %s", e.Name)
+}
+
+func entryLine(e xmlparser.Entry) string {
+	return fmt.Sprintf("%s = %s", e.Name, e.FormatValue())
+}
+
+func entryText(e xmlparser.Entry) string {
+	return strings.TrimSpace(e.Summary + "\n\n" + descriptionText(e.Description))
+}
+
+func (d *differ) enums(iface string, before, after xmlparser.Enum) {
+	element := enumElement(iface, after)
+
+	d.field(element, "bitfield", before.Bitfield, after.Bitfield)
+	d.field(element, "since", before.Since, after.Since)
+	d.description(element, descriptionText(before.Description), descriptionText(after.Description))
+
+	match(before.Entries, after.Entries, func(e xmlparser.Entry) string { return e.Name },
+		func(e xmlparser.Entry) {
+			d.add(Removed, entryElement(iface, after, e), "", entryLine(e), "")
+		},
+		func(e xmlparser.Entry) {
+			d.add(Added, entryElement(iface, after, e), e.Since, "", entryLine(e))
+		},
+		func(b, a xmlparser.Entry) {
+			child := entryElement(iface, after, a)
+
+			// the same number may be written differently
+			if !b.NumberValid || !a.NumberValid || b.Number != a.Number {
+				d.field(child, "value", b.FormatValue(), a.FormatValue())
+			}
+			d.field(child, "since", b.Since, a.Since)
+			d.description(child, entryText(b), entryText(a))
+		},
+	)
+}
+
+func entryElement(iface string, enum xmlparser.Enum, e xmlparser.Entry) xmlparser.Element {
+	return xmlparser.Element{Kind: xmlparser.ElementEntry, Interface: iface, Member: enum.Name, Child: e.Name}
+}
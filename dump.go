@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"wlpv/cli"
+	"wlpv/schema"
+)
+
+func runDump(opts cli.DumpOptions) int {
+	protocols, err := loadSelectedProtocols(opts.Offline, opts.Additions, opts.Names)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	if !opts.Compact {
+		encoder.SetIndent("", "  ")
+	}
+
+	if err := encoder.Encode(schema.NewDocument(protocols)); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"wlpv/cli"
+	"wlpv/export"
+	"wlpv/xmlparser"
+)
+
+// selectProtocols returns the namespaces and protocols of the given names,
+// or all protocols if names is empty. A protocol selected by several names
+// is returned once, the groups of protocols are never shared.
+func selectProtocols(protocols map[string][]xmlparser.Protocol, names []string) (map[string][]xmlparser.Protocol, error) {
+	if len(names) == 0 {
+		return protocols, nil
+	}
+
+	selected := make(map[string][]xmlparser.Protocol)
+	seen := make(map[string]map[string]bool) // protocol names by namespace
+
+	add := func(namespace string, protocol xmlparser.Protocol) {
+		if seen[namespace] == nil {
+			seen[namespace] = make(map[string]bool)
+		}
+
+		if !seen[namespace][protocol.Name] {
+			seen[namespace][protocol.Name] = true
+			selected[namespace] = append(selected[namespace], protocol)
+		}
+	}
+
+	for _, name := range names {
+		if group, ok := protocols[name]; ok && len(group) > 0 {
+			for _, protocol := range group {
+				add(name, protocol)
+			}
+			continue
+		}
+
+		found := false
+		for namespace, group := range protocols {
+			for _, protocol := range group {
+				if protocol.Name == name {
+					add(namespace, protocol)
+					found = true
+				}
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("no namespace or protocol named %q", name)
+		}
+	}
+
+	return selected, nil
+}
+
+// loadSelectedProtocols loads the protocols selected by names, see
+// selectProtocols. When only additions and no names are given, nothing else
+// is loaded.
+func loadSelectedProtocols(offlineMode bool, additions []xmlparser.Protocol, names []string) (map[string][]xmlparser.Protocol, error) {
+	if len(additions) > 0 && len(names) == 0 {
+		return map[string][]xmlparser.Protocol{"User": additions}, nil
+	}
+
+	protocols, _, err := loadProtocols(offlineMode, additions)
+	if err != nil {
+		return nil, err
+	}
+
+	return selectProtocols(protocols, names)
+}
+
+func runExport(opts cli.ExportOptions) int {
+	protocols, err := loadSelectedProtocols(opts.Offline, opts.Additions, opts.Names)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	switch opts.Format {
+	case "markdown":
+		if err := export.WriteMarkdown(opts.Output, protocols); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+	case "html":
+		if err := export.WriteHTML(opts.Output, protocols); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+	}
+
+	fmt.Printf("wrote %s documentation to %s\n", opts.Format, opts.Output)
+
+	return 0
+}
@@ -0,0 +1,96 @@
+package export
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"wlpv/resolver"
+	"wlpv/xmlparser"
+)
+
+var unsafeFileNameRegexp = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// fileName returns a name safe to use as a file or directory name.
+func fileName(name string) string {
+	return unsafeFileNameRegexp.ReplaceAllString(name, "_")
+}
+
+// documentPath returns the path of the document of a protocol relative to
+// the output directory.
+func documentPath(namespace string, protocol string, extension string) string {
+	return path.Join(fileName(namespace), fileName(protocol)+extension)
+}
+
+func InterfaceAnchor(iface string) string {
+	return iface
+}
+
+func MessageAnchor(iface string, kind xmlparser.MessageKind, name string) string {
+	return iface + "-" + kind.String() + "-" + name
+}
+
+func EnumAnchor(iface string, enum string) string {
+	return iface + "-enum-" + enum
+}
+
+func locationAnchor(location resolver.Location) string {
+	if location.Kind() == resolver.KindEnum {
+		return EnumAnchor(location.Interface, location.Enum)
+	}
+
+	return InterfaceAnchor(location.Interface)
+}
+
+// linker builds links from the document of one protocol to the definitions
+// of the interfaces and enums it references.
+type linker struct {
+	index     *resolver.Index
+	from      resolver.Location
+	extension string
+}
+
+func (l linker) href(location resolver.Location) string {
+	anchor := "#" + locationAnchor(location)
+
+	if location.Namespace == l.from.Namespace && location.Protocol == l.from.Protocol {
+		return anchor
+	}
+
+	return "../" + documentPath(location.Namespace, location.Protocol, l.extension) + anchor
+}
+
+// argumentLink returns the link to the interface or enum an argument refers
+// to, and false if it cannot be resolved.
+func (l linker) argumentLink(iface string, arg xmlparser.Argument) (string, bool) {
+	from := l.from
+	from.Interface = iface
+
+	location, ok := l.index.Argument(arg, from)
+	if !ok {
+		return "", false
+	}
+
+	return l.href(location), true
+}
+
+// sortedNamespaces returns the namespaces of the map in alphabetical order.
+func sortedNamespaces(protocols map[string][]xmlparser.Protocol) []string {
+	namespaces := make([]string, 0, len(protocols))
+	for namespace, group := range protocols {
+		if len(group) > 0 {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	sort.Strings(namespaces)
+
+	return namespaces
+}
+
+func sortedProtocols(protocols []xmlparser.Protocol) []xmlparser.Protocol {
+	sorted := append([]xmlparser.Protocol(nil), protocols...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted
+}
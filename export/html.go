@@ -0,0 +1,320 @@
+package export
+
+import (
+	"embed"
+	"encoding/json"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"wlpv/resolver"
+	"wlpv/xmlparser"
+)
+
+//go:embed html
+var htmlFiles embed.FS
+
+var htmlTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"paragraphs": paragraphs,
+}).ParseFS(htmlFiles, "html/*.html"))
+
+// paragraphs splits the text of a description on empty lines.
+func paragraphs(d xmlparser.Description) []string {
+	var result []string
+
+	for _, paragraph := range strings.Split(d.Text(), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			result = append(result, paragraph)
+		}
+	}
+
+	return result
+}
+
+type htmlArgument struct {
+	xmlparser.Argument
+	Target string // referenced interface or enum
+	Link   string
+}
+
+type htmlMessage struct {
+	xmlparser.Message
+	Interface string
+	Anchor    string
+	Signature string
+	Arguments []htmlArgument
+}
+
+type htmlEnum struct {
+	xmlparser.Enum
+	Interface string
+	Anchor    string
+}
+
+type htmlInterface struct {
+	xmlparser.Interface
+	Anchor   string
+	Requests []htmlMessage
+	Events   []htmlMessage
+	Enums    []htmlEnum
+}
+
+type htmlProtocolPage struct {
+	Title      string
+	Root       string // relative path from the page to the site root
+	Namespace  string
+	Protocol   xmlparser.Protocol
+	Interfaces []htmlInterface
+	Copyright  string
+}
+
+type htmlIndexProtocol struct {
+	Name    string
+	Path    string
+	Summary string
+}
+
+type htmlIndexNamespace struct {
+	Name      string
+	Protocols []htmlIndexProtocol
+}
+
+type htmlIndexPage struct {
+	Title      string
+	Root       string
+	Namespaces []htmlIndexNamespace
+}
+
+// SearchEntry is an element of the client-side search index of the site.
+type SearchEntry struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Protocol  string `json:"protocol"`
+	Summary   string `json:"summary"`
+	URL       string `json:"url"` // relative to the site root
+}
+
+func (l linker) htmlMessages(iface string, messages []xmlparser.Message) []htmlMessage {
+	var result []htmlMessage
+
+	for _, message := range messages {
+		m := htmlMessage{
+			Message:   message,
+			Interface: iface,
+			Anchor:    MessageAnchor(iface, message.Kind, message.Name),
+			Signature: signature(iface, message),
+		}
+
+		for _, arg := range message.Arguments {
+			a := htmlArgument{Argument: arg, Target: arg.Interface}
+			if a.Target == "" {
+				a.Target = arg.Enum
+			}
+
+			a.Link, _ = l.argumentLink(iface, arg)
+			m.Arguments = append(m.Arguments, a)
+		}
+
+		result = append(result, m)
+	}
+
+	return result
+}
+
+func newHTMLProtocolPage(namespace string, protocol xmlparser.Protocol, index *resolver.Index) htmlProtocolPage {
+	l := linker{
+		index:     index,
+		from:      resolver.Location{Namespace: namespace, Protocol: protocol.Name},
+		extension: ".html",
+	}
+
+	page := htmlProtocolPage{
+		Title:     protocol.Name,
+		Root:      "../",
+		Namespace: namespace,
+		Protocol:  protocol,
+		Copyright: (xmlparser.Description{Content: protocol.Copyright}).Text(),
+	}
+
+	for _, iface := range protocol.Interfaces {
+		i := htmlInterface{
+			Interface: iface,
+			Anchor:    InterfaceAnchor(iface.Name),
+			Requests:  l.htmlMessages(iface.Name, iface.Requests),
+			Events:    l.htmlMessages(iface.Name, iface.Events),
+		}
+
+		for _, enum := range iface.Enums {
+			i.Enums = append(i.Enums, htmlEnum{
+				Enum:      enum,
+				Interface: iface.Name,
+				Anchor:    EnumAnchor(iface.Name, enum.Name),
+			})
+		}
+
+		page.Interfaces = append(page.Interfaces, i)
+	}
+
+	return page
+}
+
+// HTML renders a protocol as a page of the site written by WriteHTML.
+func HTML(namespace string, protocol xmlparser.Protocol, index *resolver.Index) (string, error) {
+	var sb strings.Builder
+
+	page := newHTMLProtocolPage(namespace, protocol, index)
+	if err := htmlTemplates.ExecuteTemplate(&sb, "protocol", page); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// HTMLIndex renders the index page of the site written by WriteHTML.
+func HTMLIndex(protocols map[string][]xmlparser.Protocol) (string, error) {
+	var sb strings.Builder
+
+	page := htmlIndexPage{Title: "Wayland protocols"}
+
+	for _, namespace := range sortedNamespaces(protocols) {
+		n := htmlIndexNamespace{Name: namespace}
+
+		for _, protocol := range sortedProtocols(protocols[namespace]) {
+			n.Protocols = append(n.Protocols, htmlIndexProtocol{
+				Name:    protocol.Name,
+				Path:    documentPath(namespace, protocol.Name, ".html"),
+				Summary: protocol.Description.Summary,
+			})
+		}
+
+		page.Namespaces = append(page.Namespaces, n)
+	}
+
+	if err := htmlTemplates.ExecuteTemplate(&sb, "index", page); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// SearchIndex returns an entry for every protocol, interface, request,
+// event and enum of the site.
+func SearchIndex(protocols map[string][]xmlparser.Protocol) []SearchEntry {
+	var entries []SearchEntry
+
+	for _, namespace := range sortedNamespaces(protocols) {
+		for _, protocol := range sortedProtocols(protocols[namespace]) {
+			page := documentPath(namespace, protocol.Name, ".html")
+
+			entry := SearchEntry{Namespace: namespace, Protocol: protocol.Name}
+			add := func(name string, kind string, summary string, anchor string) {
+				entry.Name = name
+				entry.Kind = kind
+				entry.Summary = summary
+				entry.URL = page + anchor
+				entries = append(entries, entry)
+			}
+
+			add(protocol.Name, "protocol", protocol.Description.Summary, "")
+
+			for _, iface := range protocol.Interfaces {
+				add(iface.Name, "interface", iface.Description.Summary, "#"+InterfaceAnchor(iface.Name))
+
+				for _, message := range iface.Messages() {
+					add(
+						iface.Name+"."+message.Name,
+						message.Kind.String(),
+						message.Description.Summary,
+						"#"+MessageAnchor(iface.Name, message.Kind, message.Name),
+					)
+				}
+
+				for _, enum := range iface.Enums {
+					add(
+						iface.Name+"."+enum.Name,
+						"enum",
+						enum.Description.Summary,
+						"#"+EnumAnchor(iface.Name, enum.Name),
+					)
+				}
+			}
+		}
+	}
+
+	return entries
+}
+
+func searchIndexScript(protocols map[string][]xmlparser.Protocol) ([]byte, error) {
+	data, err := json.Marshal(SearchIndex(protocols))
+	if err != nil {
+		return nil, err
+	}
+
+	// a script rather than JSON, so that the site also works from file://
+	script := append([]byte("var WLPV_SEARCH_INDEX = "), data...)
+	return append(script, ";\n"...), nil
+}
+
+// HTMLSite returns the files of the static site by their path relative to
+// the site root: an index.html grouping protocols by namespace, one page
+// per protocol under <namespace>/, a stylesheet and the search index and
+// script.
+func HTMLSite(protocols map[string][]xmlparser.Protocol) (map[string][]byte, error) {
+	index := resolver.New(protocols)
+
+	files := make(map[string][]byte)
+
+	for _, namespace := range sortedNamespaces(protocols) {
+		for _, protocol := range protocols[namespace] {
+			page, err := HTML(namespace, protocol, index)
+			if err != nil {
+				return nil, err
+			}
+
+			files[documentPath(namespace, protocol.Name, ".html")] = []byte(page)
+		}
+	}
+
+	indexPage, err := HTMLIndex(protocols)
+	if err != nil {
+		return nil, err
+	}
+	files["index.html"] = []byte(indexPage)
+
+	files["search-index.js"], err = searchIndexScript(protocols)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, asset := range []string{"style.css", "search.js"} {
+		files[asset], err = htmlFiles.ReadFile("html/" + asset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// WriteHTML writes the files of HTMLSite to dir.
+func WriteHTML(dir string, protocols map[string][]xmlparser.Protocol) error {
+	files, err := HTMLSite(protocols)
+	if err != nil {
+		return err
+	}
+
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(file, content, 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
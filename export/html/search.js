@@ -0,0 +1,51 @@
+(function () {
+	var root = document.currentScript.dataset.root;
+	var input = document.querySelector("#search input");
+	var results = document.getElementById("search-results");
+
+	function search(query) {
+		var words = query.toLowerCase().split(/\s+/).filter(Boolean);
+		if (words.length === 0) {
+			return [];
+		}
+
+		var matches = [];
+		for (var i = 0; i < WLPV_SEARCH_INDEX.length && matches.length < 50; i++) {
+			var entry = WLPV_SEARCH_INDEX[i];
+			var text = (entry.name + " " + entry.protocol + " " + entry.summary).toLowerCase();
+
+			if (words.every(function (word) { return text.indexOf(word) !== -1; })) {
+				matches.push(entry);
+			}
+		}
+
+		return matches;
+	}
+
+	input.addEventListener("input", function () {
+		results.innerHTML = "";
+
+		search(input.value).forEach(function (entry) {
+			var link = document.createElement("a");
+			link.href = root + entry.url;
+			link.textContent = entry.name + " ";
+
+			var context = document.createElement("small");
+			context.textContent = entry.kind + " in " + entry.namespace + " / " + entry.protocol;
+			link.appendChild(context);
+
+			var item = document.createElement("li");
+			item.appendChild(link);
+			results.appendChild(item);
+		});
+	});
+
+	input.addEventListener("keydown", function (event) {
+		if (event.key === "Enter" && results.firstChild) {
+			window.location.href = results.firstChild.firstChild.href;
+		} else if (event.key === "Escape") {
+			input.value = "";
+			results.innerHTML = "";
+		}
+	});
+})();
@@ -0,0 +1,275 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"wlpv/resolver"
+	"wlpv/xmlparser"
+)
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		"`", "\\`",
+		"*", `\*`,
+		"<", "&lt;",
+		">", "&gt;",
+		"|", `\|`,
+		"[", `\[`,
+		"]", `\]`,
+	).Replace(s)
+}
+
+// markdownCell escapes s for use in a table cell, which cannot span lines.
+func markdownCell(s string) string {
+	return strings.ReplaceAll(markdownEscape(strings.TrimSpace(s)), "\n", "<br>")
+}
+
+func markdownDescription(sb *strings.Builder, d xmlparser.Description) {
+	if d.Summary != "" {
+		fmt.Fprintf(sb, "*%s*\n\n", markdownEscape(d.Summary))
+	}
+
+	if text := d.Text(); text != "" {
+		sb.WriteString(markdownEscape(text))
+		sb.WriteString("\n\n")
+	}
+}
+
+func markdownBadges(badges ...string) string {
+	var sb strings.Builder
+
+	for _, badge := range badges {
+		if badge != "" {
+			fmt.Fprintf(&sb, " `%s`", badge)
+		}
+	}
+
+	return sb.String()
+}
+
+func versionBadge(prefix string, version string) string {
+	if version == "" {
+		return ""
+	}
+
+	return prefix + " " + version
+}
+
+func signature(iface string, message xmlparser.Message) string {
+	var args []string
+	for _, arg := range message.Arguments {
+		args = append(args, fmt.Sprintf("%s: %s", arg.Name, arg.TypeName()))
+	}
+
+	return fmt.Sprintf("%s.%s(%s)", iface, message.Name, strings.Join(args, ", "))
+}
+
+func (l linker) markdownArgumentType(iface string, arg xmlparser.Argument) string {
+	t := markdownEscape(arg.Type)
+	if arg.AllowNull == "true" {
+		t = "?" + t
+	}
+
+	name := arg.Interface
+	if name == "" {
+		name = arg.Enum
+	}
+
+	if name == "" {
+		return t
+	}
+
+	if href, ok := l.argumentLink(iface, arg); ok {
+		return fmt.Sprintf("%s&lt;[%s](%s)&gt;", t, markdownEscape(name), href)
+	}
+
+	return fmt.Sprintf("%s&lt;%s&gt;", t, markdownEscape(name))
+}
+
+func (l linker) markdownMessage(sb *strings.Builder, iface string, message xmlparser.Message) {
+	fmt.Fprintf(sb, "<a id=\"%s\"></a>\n\n", MessageAnchor(iface, message.Kind, message.Name))
+	fmt.Fprintf(sb, "#### %s.%s%s\n\n",
+		markdownEscape(iface),
+		markdownEscape(message.Name),
+		markdownBadges(
+			fmt.Sprintf("opcode %d", message.Opcode),
+			message.Type,
+			versionBadge("since", message.Since),
+			versionBadge("deprecated since", message.DeprecatedSince),
+		),
+	)
+
+	fmt.Fprintf(sb, "```\n%s\n```\n\n", signature(iface, message))
+
+	markdownDescription(sb, message.Description)
+
+	if len(message.Arguments) == 0 {
+		return
+	}
+
+	sb.WriteString("| Argument | Type | Description |\n")
+	sb.WriteString("| --- | --- | --- |\n")
+
+	for _, arg := range message.Arguments {
+		description := arg.Summary
+		if text := arg.Description.Text(); text != "" {
+			description = strings.TrimSpace(description + "\n" + text)
+		}
+
+		fmt.Fprintf(sb, "| %s%s | %s | %s |\n",
+			markdownCell(arg.Name),
+			markdownBadges(versionBadge("since", arg.Since)),
+			l.markdownArgumentType(iface, arg),
+			markdownCell(description),
+		)
+	}
+
+	sb.WriteByte('\n')
+}
+
+func markdownEnum(sb *strings.Builder, iface string, enum xmlparser.Enum) {
+	bitfield := ""
+	if enum.Bitfield == "true" {
+		bitfield = "bitfield"
+	}
+
+	fmt.Fprintf(sb, "<a id=\"%s\"></a>\n\n", EnumAnchor(iface, enum.Name))
+	fmt.Fprintf(sb, "#### %s.%s%s\n\n",
+		markdownEscape(iface),
+		markdownEscape(enum.Name),
+		markdownBadges(bitfield, versionBadge("since", enum.Since)),
+	)
+
+	markdownDescription(sb, enum.Description)
+
+	sb.WriteString("| Entry | Value | Description |\n")
+	sb.WriteString("| --- | --- | --- |\n")
+
+	for _, entry := range enum.Entries {
+		description := entry.Summary
+		if text := entry.Description.Text(); text != "" {
+			description = strings.TrimSpace(description + "\n" + text)
+		}
+
+		fmt.Fprintf(sb, "| %s%s | `%s` | %s |\n",
+			markdownCell(entry.Name),
+			markdownBadges(versionBadge("since", entry.Since)),
+			entry.FormatValue(),
+			markdownCell(description),
+		)
+	}
+
+	sb.WriteByte('\n')
+}
+
+// Markdown renders a protocol as a Markdown document. References to
+// interfaces and enums that index resolves are linked, to an anchor of the
+// same document or to the document of another protocol as laid out by
+// WriteMarkdown.
+func Markdown(namespace string, protocol xmlparser.Protocol, index *resolver.Index) string {
+	var sb strings.Builder
+
+	l := linker{
+		index:     index,
+		from:      resolver.Location{Namespace: namespace, Protocol: protocol.Name},
+		extension: ".md",
+	}
+
+	fmt.Fprintf(&sb, "# %s\n\n", markdownEscape(protocol.Name))
+
+	markdownDescription(&sb, protocol.Description)
+
+	if len(protocol.Interfaces) > 0 {
+		sb.WriteString("## Interfaces\n\n")
+		for _, iface := range protocol.Interfaces {
+			fmt.Fprintf(&sb, "- [%s](#%s)\n", markdownEscape(iface.Name), InterfaceAnchor(iface.Name))
+		}
+		sb.WriteByte('\n')
+	}
+
+	for _, iface := range protocol.Interfaces {
+		fmt.Fprintf(&sb, "<a id=\"%s\"></a>\n\n", InterfaceAnchor(iface.Name))
+		fmt.Fprintf(&sb, "## %s%s\n\n", markdownEscape(iface.Name), markdownBadges(versionBadge("version", iface.Version)))
+
+		markdownDescription(&sb, iface.Description)
+
+		if len(iface.Requests) > 0 {
+			sb.WriteString("### Requests\n\n")
+			for _, request := range iface.Requests {
+				l.markdownMessage(&sb, iface.Name, request)
+			}
+		}
+
+		if len(iface.Events) > 0 {
+			sb.WriteString("### Events\n\n")
+			for _, event := range iface.Events {
+				l.markdownMessage(&sb, iface.Name, event)
+			}
+		}
+
+		if len(iface.Enums) > 0 {
+			sb.WriteString("### Enums\n\n")
+			for _, enum := range iface.Enums {
+				markdownEnum(&sb, iface.Name, enum)
+			}
+		}
+	}
+
+	if copyright := (xmlparser.Description{Content: protocol.Copyright}).Text(); copyright != "" {
+		sb.WriteString("## Copyright\n\n```\n")
+		sb.WriteString(copyright)
+		sb.WriteString("\n```\n")
+	}
+
+	return sb.String()
+}
+
+// MarkdownIndex renders the list of the documents written by WriteMarkdown,
+// grouped by namespace.
+func MarkdownIndex(protocols map[string][]xmlparser.Protocol) string {
+	var sb strings.Builder
+
+	sb.WriteString("# Wayland protocols\n\n")
+
+	for _, namespace := range sortedNamespaces(protocols) {
+		fmt.Fprintf(&sb, "## %s\n\n", markdownEscape(namespace))
+
+		for _, protocol := range sortedProtocols(protocols[namespace]) {
+			fmt.Fprintf(&sb, "- [%s](%s)", markdownEscape(protocol.Name), documentPath(namespace, protocol.Name, ".md"))
+
+			if protocol.Description.Summary != "" {
+				fmt.Fprintf(&sb, " - %s", markdownEscape(protocol.Description.Summary))
+			}
+
+			sb.WriteByte('\n')
+		}
+
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+// WriteMarkdown writes one document per protocol to dir/<namespace>/ and an
+// index.md listing all of them.
+func WriteMarkdown(dir string, protocols map[string][]xmlparser.Protocol) error {
+	index := resolver.New(protocols)
+
+	for _, namespace := range sortedNamespaces(protocols) {
+		if err := os.MkdirAll(filepath.Join(dir, fileName(namespace)), 0o755); err != nil {
+			return err
+		}
+
+		for _, protocol := range protocols[namespace] {
+			file := filepath.Join(dir, filepath.FromSlash(documentPath(namespace, protocol.Name, ".md")))
+			if err := os.WriteFile(file, []byte(Markdown(namespace, protocol, index)), 0o644); err != nil {
+				return err
+			}
+		}
+	}
+
+	return os.WriteFile(filepath.Join(dir, "index.md"), []byte(MarkdownIndex(protocols)), 0o644)
+}
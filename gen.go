@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"wlpv/cli"
+	"wlpv/gen"
+	"wlpv/xmlparser"
+)
+
+// cHeaders returns the headers of the protocols, named like those of
+// wayland-scanner.
+func cHeaders(protocols []xmlparser.Protocol, side gen.Side) (map[string][]byte, error) {
+	suffix := "-client-protocol.h"
+	if side == gen.Server {
+		suffix = "-server-protocol.h"
+	}
+
+	files := make(map[string][]byte)
+
+	for _, protocol := range protocols {
+		header, err := gen.CHeader(protocol, side)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", protocol.Name, err)
+		}
+
+		files[protocol.Name+suffix] = header
+	}
+
+	return files, nil
+}
+
+func runGen(opts cli.GenOptions) int {
+	protocols, err := loadSelectedProtocols(opts.Offline, opts.Additions, opts.Names)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	namespaces := make([]string, 0, len(protocols))
+	for namespace := range protocols {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	var selected []xmlparser.Protocol
+	for _, namespace := range namespaces {
+		selected = append(selected, protocols[namespace]...)
+	}
+
+	side := gen.Client
+	if opts.Server {
+		side = gen.Server
+	}
+
+	var files map[string][]byte
+
+	switch opts.Language {
+	case cli.GenLanguageGo:
+		files, err = gen.Go(selected, gen.GoOptions{Package: opts.Package, Side: side})
+	case cli.GenLanguageCHeader:
+		files, err = cHeaders(selected, side)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if err := os.MkdirAll(opts.Output, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(opts.Output, name), content, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+	}
+
+	fmt.Printf("wrote %s bindings of %d protocols to %s\n", opts.Language, len(selected), opts.Output)
+
+	return 0
+}
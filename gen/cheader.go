@@ -0,0 +1,697 @@
+package gen
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"wlpv/xmlparser"
+)
+
+// The C header follows the layout of wayland-scanner's client-header and
+// server-header output, so that both can be diffed. The layout is that of
+// scannerVersion, whose client headers the tests compare against.
+const scannerVersion = "1.22.0"
+
+// cWriter writes C source, printf returns the number of bytes written so
+// that columns can be tracked.
+type cWriter struct {
+	strings.Builder
+}
+
+func (w *cWriter) printf(format string, a ...any) int {
+	n, _ := fmt.Fprintf(w, format, a...)
+	return n
+}
+
+func cUppercase(name string) string {
+	return strings.ToUpper(name)
+}
+
+// cIndent returns the tabs and spaces that indent to column n.
+func cIndent(n int) string {
+	return strings.Repeat("\t", n/8) + strings.Repeat(" ", n%8)
+}
+
+// formatTextToComment writes the lines of a description as the lines of a
+// comment, with the indentation of each line removed.
+func (w *cWriter) formatTextToComment(text string) {
+	bol := true
+	start := 0
+
+	for i := 0; i <= len(text); i++ {
+		var c byte
+		if i < len(text) {
+			c = text[i]
+		}
+
+		if bol && (c == ' ' || c == '\t') {
+			continue
+		} else if bol {
+			bol = false
+			start = i
+		}
+
+		if c == '\n' || (i == len(text) && start != i) {
+			space := ""
+			if i > start {
+				space = " "
+			}
+
+			w.printf(" *%s%s\n", space, text[start:i])
+			bol = true
+		}
+	}
+}
+
+// descDump writes a description reflowed to 72 columns, after the prefix
+// which must contain '*'.
+func (w *cWriter) descDump(desc *string, prefix string) {
+	col := 0
+	i := 0
+	for ; prefix[i] != '*'; i++ {
+		if prefix[i] == '\t' {
+			col = (col + 8) &^ 7
+		} else {
+			col++
+		}
+	}
+
+	w.printf("%s", prefix)
+
+	if desc == nil {
+		w.printf("(none)\n")
+		return
+	}
+
+	startcol := col
+	col += len(prefix[i:])
+
+	hang := byte(' ')
+	if col-startcol > 2 {
+		hang = '\t'
+	}
+
+	text := *desc
+	for i := 0; i < len(text); {
+		k := i
+		newlines := 0
+		for i < len(text) && isCSpace(text[i]) {
+			if text[i] == '\n' {
+				newlines++
+			}
+			i++
+		}
+		if i == len(text) {
+			break
+		}
+
+		j := i
+		for i < len(text) && !isCSpace(text[i]) {
+			i++
+		}
+
+		if newlines > 1 {
+			w.printf("\n%s*", cIndent(startcol))
+		}
+		if newlines > 1 || col+i-j > 72 {
+			w.printf("\n%s*%c", cIndent(startcol), hang)
+			col = startcol
+		}
+
+		if col > startcol && k > 0 {
+			col += w.printf(" ")
+		}
+		col += w.printf("%s", text[j:i])
+	}
+
+	w.printf("\n")
+}
+
+func isCSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
+}
+
+func hasDescription(d xmlparser.Description) bool {
+	return d.Summary != "" || d.Content != ""
+}
+
+// descriptionText returns the text of a description, nil if it has none.
+func descriptionText(d xmlparser.Description) *string {
+	if d.Content == "" {
+		return nil
+	}
+
+	return &d.Content
+}
+
+func cVersion(s string) int {
+	if v, err := strconv.Atoi(s); err == nil && v > 0 {
+		return v
+	}
+
+	return 1
+}
+
+// cType returns the C type of an argument, ending with a space or '*'.
+func cType(arg xmlparser.Argument) string {
+	switch arg.Type {
+	case "new_id", "uint":
+		return "uint32_t "
+	case "fixed":
+		return "wl_fixed_t "
+	case "string":
+		return "const char *"
+	case "object":
+		return fmt.Sprintf("struct %s *", arg.Interface)
+	case "array":
+		return "struct wl_array *"
+	}
+
+	return "int32_t "
+}
+
+// CHeader returns the client or server C header of the protocol.
+func CHeader(protocol xmlparser.Protocol, side Side) ([]byte, error) {
+	w := &cWriter{}
+
+	sideName := "CLIENT"
+	include := "wayland-client"
+	if side == Server {
+		sideName = "SERVER"
+		include = "wayland-server"
+	}
+
+	upper := cUppercase(protocol.Name)
+
+	w.printf("/* Generated by wlpv in the layout of wayland-scanner %s */\n\n", scannerVersion)
+	w.printf("#ifndef %s_%s_PROTOCOL_H\n#define %s_%s_PROTOCOL_H\n\n", upper, sideName, upper, sideName)
+	w.printf("#include <stdint.h>\n#include <stddef.h>\n#include \"%s.h\"\n\n", include)
+	w.printf("#ifdef  __cplusplus\nextern \"C\" {\n#endif\n\n")
+
+	if side == Server {
+		w.printf("struct wl_client;\nstruct wl_resource;\n\n")
+	}
+
+	w.mainpageBlurb(protocol)
+
+	var types []string
+	for _, iface := range protocol.Interfaces {
+		for _, message := range iface.Messages() {
+			for _, arg := range message.Arguments {
+				if (arg.Type == "object" || arg.Type == "new_id") && arg.Interface != "" {
+					types = append(types, arg.Interface)
+				}
+			}
+		}
+	}
+	for _, iface := range protocol.Interfaces {
+		types = append(types, iface.Name)
+	}
+	sort.Strings(types)
+
+	for i, name := range types {
+		if i > 0 && types[i-1] == name {
+			continue
+		}
+		w.printf("struct %s;\n", name)
+	}
+	w.printf("\n")
+
+	for _, iface := range protocol.Interfaces {
+		w.printf("#ifndef %s_INTERFACE\n", cUppercase(iface.Name))
+		w.printf("#define %s_INTERFACE\n", cUppercase(iface.Name))
+		w.printf("/**\n * @page page_iface_%s %s\n", iface.Name, iface.Name)
+		if iface.Description.Content != "" {
+			w.printf(" * @section page_iface_%s_desc Description\n", iface.Name)
+			w.formatTextToComment(iface.Description.Content)
+		}
+		w.printf(" * @section page_iface_%s_api API\n * See @ref iface_%s.\n */\n", iface.Name, iface.Name)
+		w.printf("/**\n * @defgroup iface_%s The %s interface\n", iface.Name, iface.Name)
+		if iface.Description.Content != "" {
+			w.formatTextToComment(iface.Description.Content)
+		}
+		w.printf(" */\n")
+		w.printf("extern const struct wl_interface %s_interface;\n", iface.Name)
+		w.printf("#endif\n")
+	}
+
+	w.printf("\n")
+
+	for _, iface := range protocol.Interfaces {
+		w.enumerations(iface, side == Server)
+
+		if side == Server {
+			w.structs(iface.Requests, iface, side)
+			w.opcodes(iface.Events, iface)
+			w.opcodeVersions(iface.Events, iface)
+			w.opcodeVersions(iface.Requests, iface)
+			w.eventWrappers(iface.Events, iface)
+		} else {
+			w.structs(iface.Events, iface, side)
+			w.opcodes(iface.Requests, iface)
+			w.opcodeVersions(iface.Events, iface)
+			w.opcodeVersions(iface.Requests, iface)
+			if err := w.stubs(iface.Requests, iface); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	w.printf("#ifdef  __cplusplus\n}\n#endif\n\n#endif\n")
+
+	return []byte(w.String()), nil
+}
+
+func (w *cWriter) mainpageBlurb(protocol xmlparser.Protocol) {
+	w.printf("/**\n * @page page_%s The %s protocol\n", protocol.Name, protocol.Name)
+
+	if protocol.Description.Summary != "" {
+		w.printf(" * %s\n *\n", protocol.Description.Summary)
+	}
+
+	if protocol.Description.Content != "" {
+		w.printf(" * @section page_desc_%s Description\n", protocol.Name)
+		w.formatTextToComment(protocol.Description.Content)
+		w.printf(" *\n")
+	}
+
+	w.printf(" * @section page_ifaces_%s Interfaces\n", protocol.Name)
+	for _, iface := range protocol.Interfaces {
+		w.printf(" * - @subpage page_iface_%s - %s\n", iface.Name, iface.Description.Summary)
+	}
+
+	if protocol.Copyright != "" {
+		w.printf(" * @section page_copyright_%s Copyright\n", protocol.Name)
+		w.printf(" * <pre>\n")
+		w.formatTextToComment(protocol.Copyright)
+		w.printf(" * </pre>\n")
+	}
+
+	w.printf(" */\n")
+}
+
+func (w *cWriter) enumerations(iface xmlparser.Interface, withValidators bool) {
+	ifaceUpper := cUppercase(iface.Name)
+
+	for _, enum := range iface.Enums {
+		enumUpper := cUppercase(enum.Name)
+
+		w.printf("#ifndef %s_%s_ENUM\n", ifaceUpper, enumUpper)
+		w.printf("#define %s_%s_ENUM\n", ifaceUpper, enumUpper)
+
+		if hasDescription(enum.Description) {
+			w.printf("/**\n * @ingroup iface_%s\n", iface.Name)
+			w.formatTextToComment(enum.Description.Summary)
+			if enum.Description.Content != "" {
+				w.formatTextToComment(enum.Description.Content)
+			}
+			w.printf(" */\n")
+		}
+
+		w.printf("enum %s_%s {\n", iface.Name, enum.Name)
+		for _, entry := range enum.Entries {
+			since := cVersion(entry.Since)
+			described := hasDescription(entry.Description)
+
+			if entry.Summary != "" || since > 1 || described {
+				w.printf("\t/**\n")
+				if entry.Summary != "" {
+					w.printf("\t * %s\n", entry.Summary)
+				}
+				if described {
+					w.printf("\t * %s\n\t *\n", entry.Description.Summary)
+					if text := descriptionText(entry.Description); text != nil {
+						w.descDump(text, "\t * ")
+					}
+				}
+				if since > 1 {
+					w.printf("\t * @since %d\n", since)
+				}
+				w.printf("\t */\n")
+			}
+
+			w.printf("\t%s_%s_%s = %s,\n", ifaceUpper, enumUpper, cUppercase(entry.Name), entry.Value)
+		}
+		w.printf("};\n")
+
+		for _, entry := range enum.Entries {
+			since := cVersion(entry.Since)
+			if since == 1 {
+				continue
+			}
+
+			w.printf("/**\n * @ingroup iface_%s\n */\n", iface.Name)
+			w.printf("#define %s_%s_%s_SINCE_VERSION %d\n", ifaceUpper, enumUpper, cUppercase(entry.Name), since)
+		}
+
+		if withValidators {
+			w.printf("/**\n"+
+				" * @ingroup iface_%s\n"+
+				" * Validate a %s %s value.\n"+
+				" *\n"+
+				" * @return true on success, false on error.\n"+
+				" * @ref %s_%s\n"+
+				" */\n"+
+				"static inline bool\n"+
+				"%s_%s_is_valid(uint32_t value, uint32_t version) {\n",
+				iface.Name, iface.Name, enum.Name,
+				iface.Name, enum.Name,
+				iface.Name, enum.Name)
+
+			if enum.Bitfield == "true" {
+				w.printf("\tuint32_t valid = 0;\n")
+				for _, entry := range enum.Entries {
+					w.printf("\tif (version >= %d)\n\t\tvalid |= %s_%s_%s;\n",
+						cVersion(entry.Since), ifaceUpper, enumUpper, cUppercase(entry.Name))
+				}
+				w.printf("\treturn (value & ~valid) == 0;\n")
+			} else {
+				w.printf("\tswitch (value) {\n")
+				for _, entry := range enum.Entries {
+					cast := ""
+					if strings.HasPrefix(entry.Value, "-") {
+						cast = "(uint32_t)"
+					}
+					w.printf("\tcase %s%s_%s_%s:\n\t\treturn version >= %d;\n",
+						cast, ifaceUpper, enumUpper, cUppercase(entry.Name), cVersion(entry.Since))
+				}
+				w.printf("\tdefault:\n\t\treturn false;\n\t}\n")
+			}
+			w.printf("}\n")
+		}
+
+		w.printf("#endif /* %s_%s_ENUM */\n\n", ifaceUpper, enumUpper)
+	}
+}
+
+// structs writes the listener of the events of a client, or the interface
+// of the requests of a server.
+func (w *cWriter) structs(messages []xmlparser.Message, iface xmlparser.Interface, side Side) {
+	if len(messages) == 0 {
+		return
+	}
+
+	kind := "listener"
+	if side == Server {
+		kind = "interface"
+	}
+
+	w.printf("/**\n * @ingroup iface_%s\n * @struct %s_%s\n */\n", iface.Name, iface.Name, kind)
+	w.printf("struct %s_%s {\n", iface.Name, kind)
+
+	for _, message := range messages {
+		w.printf("\t/**\n")
+		if hasDescription(message.Description) {
+			if message.Description.Summary != "" {
+				w.printf("\t * %s\n", message.Description.Summary)
+			}
+			w.printf("\t *\n")
+			w.descDump(descriptionText(message.Description), "\t * ")
+		}
+
+		for _, arg := range message.Arguments {
+			if side == Server && arg.Type == "new_id" && arg.Interface == "" {
+				w.printf("\t * @param interface name of the objects interface\n" +
+					"\t * @param version version of the objects interface\n")
+			}
+
+			if arg.Summary != "" {
+				w.printf("\t * @param %s %s\n", arg.Name, arg.Summary)
+			}
+		}
+
+		if since := cVersion(message.Since); since > 1 {
+			w.printf("\t * @since %d\n", since)
+		}
+		if message.DeprecatedSince != "" {
+			w.printf("\t * @deprecated Deprecated since version %d\n", cVersion(message.DeprecatedSince))
+		}
+		w.printf("\t */\n")
+		w.printf("\tvoid (*%s)(", message.Name)
+
+		n := len(message.Name) + 17
+		if side == Server {
+			w.printf("struct wl_client *client,\n%sstruct wl_resource *resource", cIndent(n))
+		} else {
+			w.printf("void *data,\n")
+			w.printf("%sstruct %s *%s", cIndent(n), iface.Name, iface.Name)
+		}
+
+		for _, arg := range message.Arguments {
+			w.printf(",\n%s", cIndent(n))
+
+			switch {
+			case side == Server && arg.Type == "object":
+				w.printf("struct wl_resource *")
+			case side == Server && arg.Type == "new_id" && arg.Interface == "":
+				w.printf("const char *interface, uint32_t version, uint32_t ")
+			case side == Client && arg.Type == "object" && arg.Interface == "":
+				w.printf("void *")
+			case side == Client && arg.Type == "new_id":
+				w.printf("struct %s *", arg.Interface)
+			default:
+				w.printf("%s", cType(arg))
+			}
+
+			w.printf("%s", arg.Name)
+		}
+
+		w.printf(");\n")
+	}
+
+	w.printf("};\n\n")
+
+	if side == Client {
+		w.printf("/**\n * @ingroup iface_%s\n */\n", iface.Name)
+		w.printf("static inline int\n"+
+			"%s_add_listener(struct %s *%s,\n"+
+			"%sconst struct %s_listener *listener, void *data)\n"+
+			"{\n"+
+			"\treturn wl_proxy_add_listener((struct wl_proxy *) %s,\n"+
+			"%s(void (**)(void)) listener, data);\n"+
+			"}\n\n",
+			iface.Name, iface.Name, iface.Name,
+			cIndent(14+len(iface.Name)),
+			iface.Name,
+			iface.Name,
+			cIndent(37))
+	}
+}
+
+func (w *cWriter) opcodes(messages []xmlparser.Message, iface xmlparser.Interface) {
+	if len(messages) == 0 {
+		return
+	}
+
+	for opcode, message := range messages {
+		w.printf("#define %s_%s %d\n", cUppercase(iface.Name), cUppercase(message.Name), opcode)
+	}
+
+	w.printf("\n")
+}
+
+func (w *cWriter) opcodeVersions(messages []xmlparser.Message, iface xmlparser.Interface) {
+	for _, message := range messages {
+		w.printf("/**\n * @ingroup iface_%s\n */\n", iface.Name)
+		w.printf("#define %s_%s_SINCE_VERSION %d\n", cUppercase(iface.Name), cUppercase(message.Name), cVersion(message.Since))
+	}
+
+	w.printf("\n")
+}
+
+// stubs writes the inline functions of a client that send the requests.
+func (w *cWriter) stubs(messages []xmlparser.Message, iface xmlparser.Interface) error {
+	name := iface.Name
+	upper := cUppercase(name)
+
+	w.printf("/** @ingroup iface_%s */\n", name)
+	w.printf("static inline void\n"+
+		"%s_set_user_data(struct %s *%s, void *user_data)\n"+
+		"{\n"+
+		"\twl_proxy_set_user_data((struct wl_proxy *) %s, user_data);\n"+
+		"}\n\n",
+		name, name, name, name)
+
+	w.printf("/** @ingroup iface_%s */\n", name)
+	w.printf("static inline void *\n"+
+		"%s_get_user_data(struct %s *%s)\n"+
+		"{\n"+
+		"\treturn wl_proxy_get_user_data((struct wl_proxy *) %s);\n"+
+		"}\n\n",
+		name, name, name, name)
+
+	w.printf("static inline uint32_t\n"+
+		"%s_get_version(struct %s *%s)\n"+
+		"{\n"+
+		"\treturn wl_proxy_get_version((struct wl_proxy *) %s);\n"+
+		"}\n\n",
+		name, name, name, name)
+
+	hasDestructor := false
+	hasDestroy := false
+	for _, message := range messages {
+		if message.Type == "destructor" {
+			hasDestructor = true
+		}
+		if message.Name == "destroy" {
+			hasDestroy = true
+		}
+	}
+
+	if !hasDestructor && hasDestroy {
+		return fmt.Errorf("interface %s has method named destroy but no destructor", name)
+	}
+
+	if !hasDestroy && name != "wl_display" {
+		w.printf("/** @ingroup iface_%s */\n", name)
+		w.printf("static inline void\n"+
+			"%s_destroy(struct %s *%s)\n"+
+			"{\n"+
+			"\twl_proxy_destroy((struct wl_proxy *) %s);\n"+
+			"}\n\n",
+			name, name, name, name)
+	}
+
+	for _, message := range messages {
+		var ret *xmlparser.Argument
+		newIDs := 0
+		for i, arg := range message.Arguments {
+			if arg.Type == "new_id" {
+				ret = &message.Arguments[i]
+				newIDs++
+			}
+		}
+
+		if newIDs > 1 {
+			// wayland-scanner warns and emits no stub either
+			continue
+		}
+
+		flags := "0"
+		if message.Type == "destructor" {
+			flags = "WL_MARSHAL_FLAG_DESTROY"
+		}
+
+		w.printf("/**\n * @ingroup iface_%s\n", name)
+		if message.Description.Content != "" {
+			w.formatTextToComment(message.Description.Content)
+		}
+		// unlike listeners, the stubs do not document their version
+		if message.DeprecatedSince != "" {
+			w.printf(" *\n * @deprecated Deprecated since version %d\n", cVersion(message.DeprecatedSince))
+		}
+		w.printf(" */\n")
+
+		switch {
+		case ret != nil && ret.Interface == "":
+			w.printf("static inline void *\n")
+		case ret != nil:
+			w.printf("static inline struct %s *\n", ret.Interface)
+		default:
+			w.printf("static inline void\n")
+		}
+
+		w.printf("%s_%s(struct %s *%s", name, message.Name, name, name)
+
+		for _, arg := range message.Arguments {
+			if arg.Type == "new_id" && arg.Interface == "" {
+				w.printf(", const struct wl_interface *interface, uint32_t version")
+				continue
+			} else if arg.Type == "new_id" {
+				continue
+			}
+
+			w.printf(", ")
+			if arg.Type == "object" && arg.Interface == "" {
+				w.printf("void *")
+			} else {
+				w.printf("%s", cType(arg))
+			}
+			w.printf("%s", arg.Name)
+		}
+
+		w.printf(")\n{\n")
+
+		switch {
+		case ret != nil && ret.Interface == "":
+			w.printf("\tstruct wl_proxy *%s;\n\n"+
+				"\t%s = wl_proxy_marshal_flags((struct wl_proxy *) %s,\n"+
+				"\t\t\t %s_%s, interface, version, %s",
+				ret.Name, ret.Name, name, upper, cUppercase(message.Name), flags)
+		case ret != nil:
+			w.printf("\tstruct wl_proxy *%s;\n\n"+
+				"\t%s = wl_proxy_marshal_flags((struct wl_proxy *) %s,\n"+
+				"\t\t\t %s_%s, &%s_interface, wl_proxy_get_version((struct wl_proxy *) %s), %s",
+				ret.Name, ret.Name, name, upper, cUppercase(message.Name), ret.Interface, name, flags)
+		default:
+			w.printf("\twl_proxy_marshal_flags((struct wl_proxy *) %s,\n"+
+				"\t\t\t %s_%s, NULL, wl_proxy_get_version((struct wl_proxy *) %s), %s",
+				name, upper, cUppercase(message.Name), name, flags)
+		}
+
+		for _, arg := range message.Arguments {
+			if arg.Type == "new_id" {
+				if arg.Interface == "" {
+					w.printf(", interface->name, version")
+				}
+				w.printf(", NULL")
+			} else {
+				w.printf(", %s", arg.Name)
+			}
+		}
+		w.printf(");\n")
+
+		switch {
+		case ret != nil && ret.Interface == "":
+			w.printf("\n\treturn (void *) %s;\n", ret.Name)
+		case ret != nil:
+			w.printf("\n\treturn (struct %s *) %s;\n", ret.Interface, ret.Name)
+		}
+
+		w.printf("}\n\n")
+	}
+
+	return nil
+}
+
+// eventWrappers writes the inline functions of a server that send the
+// events.
+func (w *cWriter) eventWrappers(messages []xmlparser.Message, iface xmlparser.Interface) {
+	// libwayland-server has hand written functions for the display
+	if iface.Name == "wl_display" {
+		return
+	}
+
+	for _, message := range messages {
+		w.printf("/**\n"+
+			" * @ingroup iface_%s\n"+
+			" * Sends an %s event to the client owning the resource.\n",
+			iface.Name, message.Name)
+		w.printf(" * @param resource_ The client's resource\n")
+		for _, arg := range message.Arguments {
+			if arg.Summary != "" {
+				w.printf(" * @param %s %s\n", arg.Name, arg.Summary)
+			}
+		}
+		w.printf(" */\n")
+		w.printf("static inline void\n%s_send_%s(struct wl_resource *resource_", iface.Name, message.Name)
+
+		for _, arg := range message.Arguments {
+			w.printf(", ")
+			switch arg.Type {
+			case "new_id", "object":
+				w.printf("struct wl_resource *")
+			default:
+				w.printf("%s", cType(arg))
+			}
+			w.printf("%s", arg.Name)
+		}
+
+		w.printf(")\n{\n\twl_resource_post_event(resource_, %s_%s", cUppercase(iface.Name), cUppercase(message.Name))
+		for _, arg := range message.Arguments {
+			w.printf(", %s", arg.Name)
+		}
+		w.printf(");\n}\n\n")
+	}
+}
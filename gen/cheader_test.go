@@ -0,0 +1,66 @@
+package gen
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"wlpv/xmlparser"
+)
+
+// The headers in testdata are the output of wayland-scanner 1.22.0
+// client-header, as vendored by GLFW. The protocol files are those they were
+// generated from.
+func TestCHeader(t *testing.T) {
+	tests := []struct {
+		protocol string
+		side     Side
+		header   string
+	}{
+		{"xdg-shell.xml", Client, "xdg-shell-client-protocol.h"},
+		{"pointer-constraints-unstable-v1.xml", Client, "pointer-constraints-unstable-v1-client-protocol.h"},
+		{"viewporter.xml", Client, "viewporter-client-protocol.h"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", test.protocol))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			protocol, err := xmlparser.ParseProtocolFile(test.protocol, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := CHeader(protocol, test.side)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := os.ReadFile(filepath.Join("testdata", test.header))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the first line names the generator
+			gotLines := bytes.Split(got, []byte("\n"))[1:]
+			wantLines := bytes.Split(want, []byte("\n"))[1:]
+
+			for i := range max(len(gotLines), len(wantLines)) {
+				var gotLine, wantLine []byte
+				if i < len(gotLines) {
+					gotLine = gotLines[i]
+				}
+				if i < len(wantLines) {
+					wantLine = wantLines[i]
+				}
+
+				if !bytes.Equal(gotLine, wantLine) {
+					t.Fatalf("line %d differs from wayland-scanner:\ngot:  %q\nwant: %q", i+2, gotLine, wantLine)
+				}
+			}
+		})
+	}
+}
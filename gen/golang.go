@@ -0,0 +1,637 @@
+package gen
+
+import (
+	_ "embed"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+	"wlpv/xmlparser"
+)
+
+//go:embed golang/runtime.go.txt
+var goRuntime string
+
+const goRuntimeImports = `import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+`
+
+const goHeader = "// Code generated by wlpv gen go. DO NOT EDIT.\n\n"
+
+// Side is the end of the connection bindings are generated for, a client
+// sends requests and handles events, a server the other way around.
+type Side uint8
+
+const (
+	Client Side = iota
+	Server
+)
+
+type GoOptions struct {
+	Package string
+	Side    Side
+}
+
+var goKeywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true,
+	"default": true, "defer": true, "else": true, "fallthrough": true,
+	"for": true, "func": true, "go": true, "goto": true, "if": true,
+	"import": true, "interface": true, "map": true, "package": true,
+	"range": true, "return": true, "select": true, "struct": true,
+	"switch": true, "type": true, "var": true,
+
+	// names used by the generated code
+	"obj": true, "enc": true, "dec": true, "err": true, "opcode": true,
+}
+
+// goName returns the exported Go name of a snake_case name.
+func goName(name string) string {
+	var sb strings.Builder
+
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		sb.WriteString(string(runes))
+	}
+
+	return sb.String()
+}
+
+// goLocalName returns the Go name of an argument.
+func goLocalName(name string) string {
+	exported := []rune(goName(name))
+	if len(exported) == 0 {
+		return "arg"
+	}
+
+	exported[0] = unicode.ToLower(exported[0])
+
+	local := string(exported)
+	switch {
+	case local == "interface":
+		local = "iface"
+	case local == "type":
+		local = "typ"
+	case goKeywords[local]:
+		local += "_"
+	case unicode.IsDigit(exported[0]):
+		local = "arg" + local
+	}
+
+	return local
+}
+
+// goMethodName returns the Go name of a message, avoiding the methods every
+// object has.
+func goMethodName(name string) string {
+	method := goName(name)
+
+	switch method {
+	case "Base", "InterfaceName", "Conn", "ID", "Version", "Init", "Dispatch", "SetHandler":
+		method += "_"
+	}
+
+	return method
+}
+
+type goEnum struct {
+	typeName string
+	bitfield bool
+}
+
+type goGenerator struct {
+	opts       GoOptions
+	interfaces map[string]bool   // generated interfaces
+	enums      map[string]goEnum // by "interface.enum"
+}
+
+// Go returns the Go bindings of the protocols by file name: a file per
+// protocol and runtime.go with what they share, the Conn to implement
+// among others.
+func Go(protocols []xmlparser.Protocol, opts GoOptions) (map[string][]byte, error) {
+	g := goGenerator{
+		opts:       opts,
+		interfaces: make(map[string]bool),
+		enums:      make(map[string]goEnum),
+	}
+
+	declared := make(map[string]string) // Go name -> what it declares
+
+	declare := func(name string, what string) error {
+		if previous, ok := declared[name]; ok {
+			return fmt.Errorf("%s and %s are both named %s in Go", previous, what, name)
+		}
+
+		declared[name] = what
+		return nil
+	}
+
+	for _, protocol := range protocols {
+		for _, iface := range protocol.Interfaces {
+			if g.interfaces[iface.Name] {
+				return nil, fmt.Errorf("interface %s is defined more than once", iface.Name)
+			}
+			g.interfaces[iface.Name] = true
+
+			for _, suffix := range []string{"", "Handler", "Version"} {
+				if err := declare(goName(iface.Name)+suffix, "interface "+iface.Name); err != nil {
+					return nil, err
+				}
+			}
+
+			for _, enum := range iface.Enums {
+				typeName := goName(iface.Name) + goName(enum.Name)
+				if err := declare(typeName, "enum "+iface.Name+"."+enum.Name); err != nil {
+					return nil, err
+				}
+
+				g.enums[iface.Name+"."+enum.Name] = goEnum{
+					typeName: typeName,
+					bitfield: enum.Bitfield == "true",
+				}
+			}
+		}
+	}
+
+	files := make(map[string][]byte)
+
+	runtime := goHeader + "package " + opts.Package + "\n\n" + goRuntimeImports + "\n" + goRuntime
+	source, err := format.Source([]byte(runtime))
+	if err != nil {
+		return nil, fmt.Errorf("runtime.go: %w", err)
+	}
+	files["runtime.go"] = source
+
+	for _, protocol := range protocols {
+		name := protocol.Name + ".go"
+
+		source, err := format.Source([]byte(g.protocol(protocol)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		files[name] = source
+	}
+
+	return files, nil
+}
+
+// writeComment writes text as a comment, prefixed by indent.
+func writeComment(sb *strings.Builder, indent string, text string) {
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			fmt.Fprintf(sb, "%s//\n", indent)
+		} else {
+			fmt.Fprintf(sb, "%s// %s\n", indent, line)
+		}
+	}
+}
+
+func (g goGenerator) protocol(protocol xmlparser.Protocol) string {
+	var sb strings.Builder
+
+	sb.WriteString(goHeader)
+
+	if copyright := (xmlparser.Description{Content: protocol.Copyright}).Text(); copyright != "" {
+		writeComment(&sb, "", copyright)
+		sb.WriteByte('\n')
+	}
+
+	fmt.Fprintf(&sb, "package %s\n", g.opts.Package)
+
+	for _, iface := range protocol.Interfaces {
+		g.iface(&sb, iface)
+	}
+
+	return sb.String()
+}
+
+func (g goGenerator) outgoing(iface xmlparser.Interface) []xmlparser.Message {
+	if g.opts.Side == Server {
+		return iface.Events
+	}
+
+	return iface.Requests
+}
+
+func (g goGenerator) incoming(iface xmlparser.Interface) []xmlparser.Message {
+	if g.opts.Side == Server {
+		return iface.Requests
+	}
+
+	return iface.Events
+}
+
+func (g goGenerator) iface(sb *strings.Builder, iface xmlparser.Interface) {
+	typeName := goName(iface.Name)
+
+	version := 1
+	if v, err := strconv.Atoi(iface.Version); err == nil && v > 0 {
+		version = v
+	}
+
+	fmt.Fprintf(sb, "\n// %sVersion is the version of %s the bindings are generated from.\n", typeName, iface.Name)
+	fmt.Fprintf(sb, "const %sVersion = %d\n\n", typeName, version)
+
+	fmt.Fprintf(sb, "// %s is %s", typeName, iface.Name)
+	if iface.Description.Summary != "" {
+		fmt.Fprintf(sb, ", %s", iface.Description.Summary)
+	}
+	sb.WriteString(".\n")
+	if text := iface.Description.Text(); text != "" {
+		sb.WriteString("//\n")
+		writeComment(sb, "", text)
+	}
+
+	fmt.Fprintf(sb, "type %s struct {\n\tProxy\n\thandler %sHandler\n}\n\n", typeName, typeName)
+
+	fmt.Fprintf(sb, "func (*%s) InterfaceName() string { return %q }\n\n", typeName, iface.Name)
+
+	fmt.Fprintf(sb, "// SetHandler sets the handler of the incoming messages of the object.\n")
+	fmt.Fprintf(sb, "func (obj *%s) SetHandler(handler %sHandler) { obj.handler = handler }\n", typeName, typeName)
+
+	for opcode, message := range g.outgoing(iface) {
+		g.send(sb, iface, opcode, message)
+	}
+
+	g.handler(sb, iface)
+	g.dispatch(sb, iface)
+
+	for _, enum := range iface.Enums {
+		g.enum(sb, iface, enum)
+	}
+}
+
+// since returns the since version of a message, 1 if it has none.
+func since(message xmlparser.Message) int {
+	if v, err := strconv.Atoi(message.Since); err == nil && v > 0 {
+		return v
+	}
+
+	return 1
+}
+
+// argumentType returns the Go type of an argument, the type of its enum
+// for an integer with a generated enum.
+func (g goGenerator) argumentType(iface xmlparser.Interface, arg xmlparser.Argument) string {
+	switch arg.Type {
+	case "int", "uint":
+		if enum, ok := g.resolveEnum(iface, arg); ok {
+			return enum.typeName
+		}
+
+		if arg.Type == "int" {
+			return "int32"
+		}
+		return "uint32"
+	case "fixed":
+		return "Fixed"
+	case "string":
+		if arg.AllowNull == "true" {
+			return "*string"
+		}
+		return "string"
+	case "object", "new_id":
+		if g.interfaces[arg.Interface] {
+			return "*" + goName(arg.Interface)
+		}
+		return "Object"
+	case "array":
+		return "[]byte"
+	case "fd":
+		return "int"
+	}
+
+	return "uint32"
+}
+
+// resolveEnum resolves the enum of an argument among the generated ones.
+func (g goGenerator) resolveEnum(iface xmlparser.Interface, arg xmlparser.Argument) (goEnum, bool) {
+	if arg.Enum == "" {
+		return goEnum{}, false
+	}
+
+	ref := arg.Enum
+	if !strings.Contains(ref, ".") {
+		ref = iface.Name + "." + ref
+	}
+
+	enum, ok := g.enums[ref]
+	return enum, ok
+}
+
+func messageComment(sb *strings.Builder, method string, verb string, iface xmlparser.Interface, message xmlparser.Message) {
+	fmt.Fprintf(sb, "\n// %s %s %s.%s", method, verb, iface.Name, message.Name)
+	if message.Description.Summary != "" {
+		fmt.Fprintf(sb, ", %s", message.Description.Summary)
+	}
+	sb.WriteString(".\n")
+
+	if text := message.Description.Text(); text != "" {
+		sb.WriteString("//\n")
+		writeComment(sb, "", text)
+	}
+
+	if message.Type == "destructor" {
+		sb.WriteString("//\n// This message destroys the object.\n")
+	}
+
+	if s := since(message); s > 1 {
+		fmt.Fprintf(sb, "//\n// Since version %d.\n", s)
+	}
+
+	if message.DeprecatedSince != "" {
+		fmt.Fprintf(sb, "//\n// Deprecated: since version %s.\n", message.DeprecatedSince)
+	}
+}
+
+// send writes the method that sends a message.
+func (g goGenerator) send(sb *strings.Builder, iface xmlparser.Interface, opcode int, message xmlparser.Message) {
+	typeName := goName(iface.Name)
+	method := goMethodName(message.Name)
+
+	var (
+		params  []string
+		results []string
+		body    strings.Builder
+	)
+
+	for _, arg := range message.Arguments {
+		name := goLocalName(arg.Name)
+		argType := g.argumentType(iface, arg)
+
+		switch {
+		case arg.Type == "new_id" && arg.Interface == "":
+			// the caller picks the interface and version of the new object
+			params = append(params, name+" Object", name+"Version uint32")
+			fmt.Fprintf(&body, "\t%sID := obj.Conn().NewID(%s)\n", name, name)
+			fmt.Fprintf(&body, "\t%s.Base().Init(obj.Conn(), %sID, %sVersion)\n", name, name, name)
+			fmt.Fprintf(&body, "\tenc.PutString(%s.InterfaceName())\n", name)
+			fmt.Fprintf(&body, "\tenc.PutUint(%sVersion)\n", name)
+			fmt.Fprintf(&body, "\tenc.PutUint(%sID)\n", name)
+
+		case arg.Type == "new_id" && argType == "Object":
+			params = append(params, name+" Object")
+			fmt.Fprintf(&body, "\t%s.Base().Init(obj.Conn(), obj.Conn().NewID(%s), obj.Version())\n", name, name)
+			fmt.Fprintf(&body, "\tenc.PutUint(%s.Base().ID())\n", name)
+
+		case arg.Type == "new_id":
+			results = append(results, name+" "+argType)
+			fmt.Fprintf(&body, "\t%s = &%s{}\n", name, argType[1:])
+			fmt.Fprintf(&body, "\t%s.Init(obj.Conn(), obj.Conn().NewID(%s), obj.Version())\n", name, name)
+			fmt.Fprintf(&body, "\tenc.PutUint(%s.ID())\n", name)
+
+		default:
+			params = append(params, name+" "+argType)
+			body.WriteString("\t" + putArgument(arg, name, argType) + "\n")
+		}
+	}
+
+	results = append(results, "err error")
+
+	messageComment(sb, method, "sends", iface, message)
+
+	fmt.Fprintf(sb, "func (obj *%s) %s(%s) (%s) {\n", typeName, method, strings.Join(params, ", "), strings.Join(results, ", "))
+
+	if s := since(message); s > 1 {
+		fmt.Fprintf(sb, "\tif obj.Version() < %d {\n", s)
+		fmt.Fprintf(sb, "\t\terr = &VersionError{Interface: %q, Message: %q, Since: %d, Version: obj.Version()}\n", iface.Name, message.Name, s)
+		sb.WriteString("\t\treturn\n\t}\n\n")
+	}
+
+	sb.WriteString("\tvar enc Encoder\n")
+	sb.WriteString(body.String())
+	fmt.Fprintf(sb, "\terr = obj.Conn().Send(obj.ID(), %d, enc.Data, enc.FDs)\n", opcode)
+	sb.WriteString("\treturn\n}\n")
+}
+
+func putArgument(arg xmlparser.Argument, name string, argType string) string {
+	switch arg.Type {
+	case "int":
+		if argType != "int32" {
+			return fmt.Sprintf("enc.PutInt(int32(%s))", name)
+		}
+		return fmt.Sprintf("enc.PutInt(%s)", name)
+	case "uint":
+		if argType != "uint32" {
+			return fmt.Sprintf("enc.PutUint(uint32(%s))", name)
+		}
+		return fmt.Sprintf("enc.PutUint(%s)", name)
+	case "fixed":
+		return fmt.Sprintf("enc.PutFixed(%s)", name)
+	case "string":
+		if argType == "*string" {
+			return fmt.Sprintf("enc.PutNullableString(%s)", name)
+		}
+		return fmt.Sprintf("enc.PutString(%s)", name)
+	case "object":
+		return fmt.Sprintf("enc.PutObject(%s)", name)
+	case "array":
+		return fmt.Sprintf("enc.PutArray(%s)", name)
+	case "fd":
+		return fmt.Sprintf("enc.PutFD(%s)", name)
+	}
+
+	return fmt.Sprintf("enc.PutUint(uint32(%s))", name)
+}
+
+// incomingParameters returns the parameters of the handler method of an
+// incoming message.
+func (g goGenerator) incomingParameters(iface xmlparser.Interface, message xmlparser.Message) []string {
+	var params []string
+
+	for _, arg := range message.Arguments {
+		name := goLocalName(arg.Name)
+		argType := g.argumentType(iface, arg)
+
+		switch {
+		case arg.Type == "new_id" && argType == "Object":
+			// the handler creates the object
+			if arg.Interface == "" {
+				params = append(params, name+"Interface string", name+"Version uint32")
+			}
+			params = append(params, name+" uint32")
+		default:
+			params = append(params, name+" "+argType)
+		}
+	}
+
+	return params
+}
+
+func (g goGenerator) handler(sb *strings.Builder, iface xmlparser.Interface) {
+	typeName := goName(iface.Name)
+
+	fmt.Fprintf(sb, "\n// %sHandler handles the incoming messages of %s.\n", typeName, iface.Name)
+	fmt.Fprintf(sb, "type %sHandler interface {\n", typeName)
+
+	for i, message := range g.incoming(iface) {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+
+		summary := message.Description.Summary
+		if summary == "" {
+			summary = message.Name
+		}
+		fmt.Fprintf(sb, "\t// %s handles %s.%s, %s.\n", goMethodName(message.Name), iface.Name, message.Name, summary)
+
+		if s := since(message); s > 1 {
+			fmt.Fprintf(sb, "\t// Since version %d.\n", s)
+		}
+
+		fmt.Fprintf(sb, "\t%s(%s)\n", goMethodName(message.Name), strings.Join(g.incomingParameters(iface, message), ", "))
+	}
+
+	sb.WriteString("}\n")
+}
+
+func (g goGenerator) dispatch(sb *strings.Builder, iface xmlparser.Interface) {
+	typeName := goName(iface.Name)
+
+	fmt.Fprintf(sb, "\nfunc (obj *%s) Dispatch(opcode uint16, dec *Decoder) error {\n", typeName)
+	sb.WriteString("\tswitch opcode {\n")
+
+	for opcode, message := range g.incoming(iface) {
+		fmt.Fprintf(sb, "\tcase %d:\n", opcode)
+
+		var (
+			args    []string
+			created []string
+		)
+
+		for _, arg := range message.Arguments {
+			name := goLocalName(arg.Name)
+			argType := g.argumentType(iface, arg)
+
+			switch {
+			case arg.Type == "new_id" && argType == "Object":
+				if arg.Interface == "" {
+					fmt.Fprintf(sb, "\t\t%sInterface := dec.GetString()\n", name)
+					fmt.Fprintf(sb, "\t\t%sVersion := dec.GetUint()\n", name)
+					args = append(args, name+"Interface", name+"Version")
+				}
+				fmt.Fprintf(sb, "\t\t%s := dec.GetUint()\n", name)
+
+			case arg.Type == "new_id":
+				fmt.Fprintf(sb, "\t\t%s := &%s{}\n", name, argType[1:])
+				fmt.Fprintf(sb, "\t\t%s.Init(obj.Conn(), dec.GetUint(), obj.Version())\n", name)
+				created = append(created, name)
+
+			case arg.Type == "object" && argType != "Object":
+				fmt.Fprintf(sb, "\t\t%s, _ := dec.GetObject().(%s)\n", name, argType)
+
+			default:
+				fmt.Fprintf(sb, "\t\t%s := %s\n", name, getArgument(arg, argType))
+			}
+
+			args = append(args, name)
+		}
+
+		sb.WriteString("\t\tif dec.Err != nil {\n\t\t\treturn dec.Err\n\t\t}\n")
+
+		for _, name := range created {
+			fmt.Fprintf(sb, "\t\tobj.Conn().SetObject(%s.ID(), %s)\n", name, name)
+		}
+
+		sb.WriteString("\t\tif obj.handler != nil {\n")
+		fmt.Fprintf(sb, "\t\t\tobj.handler.%s(%s)\n", goMethodName(message.Name), strings.Join(args, ", "))
+		sb.WriteString("\t\t}\n")
+		sb.WriteString("\t\treturn nil\n")
+	}
+
+	sb.WriteString("\t}\n\n")
+	fmt.Fprintf(sb, "\treturn &OpcodeError{Interface: %q, Opcode: opcode}\n}\n", iface.Name)
+}
+
+func getArgument(arg xmlparser.Argument, argType string) string {
+	switch arg.Type {
+	case "int":
+		if argType != "int32" {
+			return argType + "(dec.GetInt())"
+		}
+		return "dec.GetInt()"
+	case "uint":
+		if argType != "uint32" {
+			return argType + "(dec.GetUint())"
+		}
+		return "dec.GetUint()"
+	case "fixed":
+		return "dec.GetFixed()"
+	case "string":
+		if argType == "*string" {
+			return "dec.GetNullableString()"
+		}
+		return "dec.GetString()"
+	case "object":
+		return "dec.GetObject()"
+	case "array":
+		return "dec.GetArray()"
+	case "fd":
+		return "dec.GetFD()"
+	}
+
+	return "dec.GetUint()"
+}
+
+func (g goGenerator) enum(sb *strings.Builder, iface xmlparser.Interface, enum xmlparser.Enum) {
+	goEnum := g.enums[iface.Name+"."+enum.Name]
+	typeName := goEnum.typeName
+
+	fmt.Fprintf(sb, "\n// %s is %s.%s", typeName, iface.Name, enum.Name)
+	if enum.Description.Summary != "" {
+		fmt.Fprintf(sb, ", %s", enum.Description.Summary)
+	}
+	sb.WriteString(".\n")
+	if text := enum.Description.Text(); text != "" {
+		sb.WriteString("//\n")
+		writeComment(sb, "", text)
+	}
+	fmt.Fprintf(sb, "type %s uint32\n\n", typeName)
+
+	sb.WriteString("const (\n")
+	for _, entry := range enum.Entries {
+		if !entry.NumberValid {
+			fmt.Fprintf(sb, "\t// %s has the invalid value %q.\n", entry.Name, entry.Value)
+			continue
+		}
+
+		if entry.Summary != "" {
+			fmt.Fprintf(sb, "\t// %s\n", entry.Summary)
+		}
+		if entry.Since != "" {
+			fmt.Fprintf(sb, "\t// Since version %s.\n", entry.Since)
+		}
+		value := entry.Value
+		if strings.HasPrefix(value, "-") {
+			value = strconv.FormatUint(uint64(entry.Number), 10)
+		}
+		fmt.Fprintf(sb, "\t%s%s %s = %s\n", typeName, goName(entry.Name), typeName, value)
+	}
+	sb.WriteString(")\n")
+
+	fmt.Fprintf(sb, "\nfunc (v %s) String() string {\n", typeName)
+	fmt.Fprintf(sb, "\treturn formatEnum(uint32(v), %t, []enumEntry{\n", goEnum.bitfield)
+	for _, entry := range enum.Entries {
+		if entry.NumberValid {
+			fmt.Fprintf(sb, "\t\t{%q, %d},\n", entry.Name, entry.Number)
+		}
+	}
+	sb.WriteString("\t})\n}\n")
+
+	if goEnum.bitfield {
+		fmt.Fprintf(sb, "\n// Has reports whether every flag set in flags is set in v.\n")
+		fmt.Fprintf(sb, "func (v %s) Has(flags %s) bool { return v&flags == flags }\n", typeName, typeName)
+	}
+}
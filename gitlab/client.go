@@ -0,0 +1,193 @@
+package gitlab
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	maxRetries    = 4
+	minRetryDelay = 500 * time.Millisecond // doubled on each retry
+	maxRetryDelay = time.Minute            // longer delays asked by GitLab are not waited for
+)
+
+// Client sends the requests of fetches, it is shared by every fetch of a
+// run. At most maxRequests requests are in flight at once, and requests
+// failing with a server error or rate limited are retried after a growing
+// delay, or the delay asked by GitLab. Once the RateLimit-* headers of a
+// response tell that no request remains, requests wait for the reset.
+type Client struct {
+	http  *http.Client
+	slots chan struct{}
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+// NewClient returns a client abandoning requests after timeout.
+func NewClient(timeout time.Duration, maxRequests int) *Client {
+	return &Client{
+		http:  &http.Client{Timeout: timeout},
+		slots: make(chan struct{}, max(maxRequests, 1)),
+	}
+}
+
+// get requests address, conditionally if etag is not empty.
+func (c *Client) get(ctx context.Context, address, etag string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := sleep(ctx, c.paused()); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.do(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		c.limit(resp.Header)
+
+		if attempt == maxRetries || resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return resp, nil
+		}
+
+		delay := retryDelay(resp.Header, attempt)
+		if delay > maxRetryDelay {
+			return resp, nil
+		}
+
+		resp.Body.Close()
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// do sends req once fewer than maxRequests are in flight, the request is
+// in flight until the body of its response is closed.
+func (c *Client) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		<-c.slots
+		return nil, err
+	}
+
+	resp.Body = &slotBody{ReadCloser: resp.Body, slots: c.slots}
+
+	return resp, nil
+}
+
+// slotBody frees the slot of its request once closed.
+type slotBody struct {
+	io.ReadCloser
+	slots chan struct{}
+	once  sync.Once
+}
+
+func (b *slotBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { <-b.slots })
+
+	return err
+}
+
+// limit pauses the requests until the reset of the rate limit if none
+// remain.
+func (c *Client) limit(header http.Header) {
+	if header.Get("RateLimit-Remaining") != "0" {
+		return
+	}
+
+	reset, ok := rateLimitReset(header)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if reset.After(c.pausedUntil) {
+		c.pausedUntil = reset
+	}
+}
+
+// paused returns how long requests must wait for the rate limit to reset.
+func (c *Client) paused() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return min(time.Until(c.pausedUntil), maxRetryDelay)
+}
+
+// retryDelay returns the delay before retrying a request whose response
+// had header: that of Retry-After, else until the rate limit resets if
+// exhausted, else an exponential backoff with jitter.
+func retryDelay(header http.Header, attempt int) time.Duration {
+	if after := header.Get("Retry-After"); after != "" {
+		if seconds, err := strconv.Atoi(after); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+
+		if date, err := http.ParseTime(after); err == nil {
+			return time.Until(date)
+		}
+	}
+
+	if header.Get("RateLimit-Remaining") == "0" {
+		if reset, ok := rateLimitReset(header); ok {
+			return time.Until(reset)
+		}
+	}
+
+	backoff := minRetryDelay << attempt
+
+	return backoff/2 + rand.N(backoff/2)
+}
+
+// rateLimitReset returns the time the rate limit of GitLab resets at, given
+// in seconds since the epoch.
+func rateLimitReset(header http.Header) (time.Time, bool) {
+	seconds, err := strconv.ParseInt(header.Get("RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(seconds, 0), true
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
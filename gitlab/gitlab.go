@@ -1,22 +1,89 @@
 package gitlab
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
+	"time"
 	"wlpv/xmlparser"
 )
 
+// File is a protocol file as fetched, Path is its path in the repository.
+// ETag identifies its content for conditional requests, if known.
+type File struct {
+	Path    string
+	Content []byte
+	ETag    string
+}
+
+// FetchResult holds the protocols of a namespace and the files they were
+// parsed from. Commit is the sha of the commit the files were read at.
+// Errors are the requests that failed, the result is incomplete if any.
 type FetchResult struct {
 	Namespace string
 	Protocols []xmlparser.Protocol
+	Files     []File
+	Commit    string
+	Errors    []*FetchError
+}
+
+// Failed reports whether nothing could be fetched.
+func (r FetchResult) Failed() bool {
+	return len(r.Files) == 0 && len(r.Errors) > 0
+}
+
+// FetchError is a failed request of the fetch of a namespace. StatusCode is
+// that of the response, 0 if none was received, and Err the cause, nil if
+// the status code is the cause. Url is empty for a file that was not
+// requested, such as a cached one.
+type FetchError struct {
+	Namespace  string
+	Path       string // of the file or tree in the repository
+	Url        string
+	StatusCode int
+	Err        error
+}
+
+func (e *FetchError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s: %d %s", e.Url, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	if e.Url == "" {
+		return e.Err.Error()
+	}
+
+	return fmt.Sprintf("%s: %v", e.Url, e.Err)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
 }
 
 type fileResponse struct {
-	Content string `json:"content"`
+	Content  string `json:"content"`
+	CommitID string `json:"commit_id"`
+}
+
+// fetchedFile is a parsed protocol along with the response it came from,
+// or the error fetching it.
+type fetchedFile struct {
+	protocol xmlparser.Protocol
+	file     File
+	commit   string
+	err      *FetchError
+}
+
+type branchResponse struct {
+	Commit struct {
+		ID string `json:"id"`
+	} `json:"commit"`
 }
 
 type treeResponse struct {
@@ -29,6 +96,7 @@ type UrlType uint8
 const (
 	UrlTypeTree UrlType = iota
 	UrlTypeFiles
+	UrlTypeBranch
 )
 
 type UrlConfig struct {
@@ -40,111 +108,296 @@ type UrlConfig struct {
 	UrlType
 }
 
+// Revision is what the protocols of a namespace were last fetched from.
+// Commit is empty if unknown.
+type Revision struct {
+	Repository string
+	Commit     string
+	FetchedAt  time.Time
+}
+
 func (u UrlConfig) url() string {
-	base := fmt.Sprintf("%s/api/v4/projects/%s%%2F%s/repository",
+	// the namespace may be a nested group, whose slashes are escaped too
+	base := fmt.Sprintf("%s/api/v4/projects/%s/repository",
 		u.Origin,
-		u.Namespace,
-		u.Repository,
+		url.PathEscape(u.Namespace+"/"+u.Repository),
 	)
 
 	formattedPath := url.PathEscape(u.Path)
+	ref := url.QueryEscape(u.Branch)
 
 	switch u.UrlType {
 	case UrlTypeFiles:
-		return fmt.Sprintf("%s/files/%s?ref=%s", base, formattedPath, u.Branch)
+		return fmt.Sprintf("%s/files/%s?ref=%s", base, formattedPath, ref)
 	case UrlTypeTree:
 		return fmt.Sprintf(
 			"%s/tree?path=%s&ref=%s&per_page=100&recursive=true",
 			base,
 			formattedPath,
-			u.Branch,
+			ref,
 		)
+	case UrlTypeBranch:
+		return fmt.Sprintf("%s/branches/%s", base, url.PathEscape(u.Branch))
 	}
 
 	return ""
 }
 
-func (u UrlConfig) Fetch(wg *sync.WaitGroup, ch chan<- FetchResult, namespace string) {
+// Fetch sends the protocols of u on ch, along with the requests that failed,
+// requesting them with c until ctx is done. Previous is an earlier fetch of
+// u, which may be empty: its files are reused without downloading them again
+// when the branch has no new commit, or when their ETag still matches.
+func (u UrlConfig) Fetch(ctx context.Context, c *Client, wg *sync.WaitGroup, ch chan<- FetchResult, namespace string, previous FetchResult) {
 	defer wg.Done()
 
-	resp, err := http.Get(u.url())
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return
+	result := FetchResult{Namespace: namespace}
+
+	previousFiles := make(map[string]File)
+	for _, file := range previous.Files {
+		previousFiles[file.Path] = file
 	}
-	defer resp.Body.Close()
 
-	result := FetchResult{Namespace: namespace}
+	if len(previous.Files) > 0 {
+		result.Commit = u.head(ctx, c)
 
-	switch u.UrlType {
-	case UrlTypeFiles:
-		protocol, err := u.handleFile(resp)
-		if err != nil {
+		// nothing was committed since the previous fetch
+		if result.Commit != "" && result.Commit == previous.Commit {
+			for _, file := range previous.Files {
+				fetched, err := parseFile(file, previous.Commit)
+				if err != nil {
+					fileUrlConfig := u
+					fileUrlConfig.UrlType = UrlTypeFiles
+					fileUrlConfig.Path = file.Path
+
+					fetched = fetchedFile{err: fileUrlConfig.fetchError(fileUrlConfig.url(), 0, err)}
+				}
+
+				result.add(fetched)
+			}
+
+			ch <- result
 			return
 		}
+	}
 
-		result.Protocols = append(result.Protocols, protocol)
+	switch u.UrlType {
+	case UrlTypeFiles:
+		result.add(u.fetchFile(ctx, c, previousFiles[u.Path]))
 
 	case UrlTypeTree:
-		filePaths, err := u.handleTree(resp)
+		filePaths, err := u.fetchTree(ctx, c)
 		if err != nil {
-			return
+			result.add(fetchedFile{err: err})
+			break
 		}
 
 		var fileWg sync.WaitGroup
-		fileCh := make(chan xmlparser.Protocol)
+		pathCh := make(chan string)
+		fileCh := make(chan fetchedFile)
 
-		for _, path := range filePaths {
+		// no more workers than requests the client sends at once
+		for range min(len(filePaths), cap(c.slots)) {
 			fileWg.Add(1)
 
-			fileUrlConfig := u
-			fileUrlConfig.UrlType = UrlTypeFiles
-			fileUrlConfig.Path = path
-
-			go func(cu UrlConfig, cwg *sync.WaitGroup, cch chan<- xmlparser.Protocol) {
+			go func(cwg *sync.WaitGroup, cpathCh <-chan string, cch chan<- fetchedFile) {
 				defer cwg.Done()
 
-				resp, err := http.Get(cu.url())
-				if err != nil || resp.StatusCode != http.StatusOK {
-					return
-				}
-				defer resp.Body.Close()
+				for path := range cpathCh {
+					fileUrlConfig := u
+					fileUrlConfig.UrlType = UrlTypeFiles
+					fileUrlConfig.Path = path
 
-				protocol, err := cu.handleFile(resp)
-				if err != nil {
-					return
+					cch <- fileUrlConfig.fetchFile(ctx, c, previousFiles[path])
 				}
-
-				cch <- protocol
-			}(fileUrlConfig, &fileWg, fileCh)
+			}(&fileWg, pathCh, fileCh)
 		}
 
 		go func() {
+			for _, path := range filePaths {
+				pathCh <- path
+			}
+			close(pathCh)
+
 			fileWg.Wait()
 			close(fileCh)
 		}()
 
-		for protocol := range fileCh {
-			result.Protocols = append(result.Protocols, protocol)
+		for fetched := range fileCh {
+			result.add(fetched)
 		}
 	}
 
 	ch <- result
 }
 
-func (u UrlConfig) handleFile(resp *http.Response) (xmlparser.Protocol, error) {
+// fetchTree returns the paths of the protocol files in the tree at u.Path,
+// read from every page of the listing.
+func (u UrlConfig) fetchTree(ctx context.Context, c *Client) ([]string, *FetchError) {
+	var filePaths []string
+
+	visited := make(map[string]bool)
+
+	for address := u.url(); address != "" && !visited[address]; {
+		visited[address] = true
+
+		resp, err := c.get(ctx, address, "")
+		if err != nil {
+			return nil, u.fetchError(address, 0, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, u.fetchError(address, resp.StatusCode, nil)
+		}
+
+		next := nextPage(address, resp.Header)
+
+		pagePaths, err := u.handleTree(resp)
+		if err != nil {
+			return nil, u.fetchError(address, resp.StatusCode, err)
+		}
+
+		filePaths = append(filePaths, pagePaths...)
+		address = next
+	}
+
+	return filePaths, nil
+}
+
+// nextPage returns the address of the page following the one at address,
+// or an empty string if it was the last. GitLab links to it in the Link
+// header, always present with keyset pagination, and gives its number in
+// X-Next-Page with offset pagination.
+func nextPage(address string, header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(link, ";")
+			if ok && strings.Contains(params, `rel="next"`) {
+				return strings.Trim(strings.TrimSpace(target), "<>")
+			}
+		}
+	}
+
+	page := header.Get("X-Next-Page")
+	if page == "" {
+		return ""
+	}
+
+	next, err := url.Parse(address)
+	if err != nil {
+		return ""
+	}
+
+	query := next.Query()
+	query.Set("page", page)
+	next.RawQuery = query.Encode()
+
+	return next.String()
+}
+
+// head returns the sha of the last commit of the branch, or an empty string
+// if it cannot be resolved, as for a tag.
+func (u UrlConfig) head(ctx context.Context, c *Client) string {
+	branch := u
+	branch.UrlType = UrlTypeBranch
+
+	resp, err := c.get(ctx, branch.url(), "")
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+
+	var branchResp branchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&branchResp); err != nil {
+		return ""
+	}
+
+	return branchResp.Commit.ID
+}
+
+// fetchFile fetches the file at u.Path, previous is its earlier fetch,
+// reused if unchanged.
+func (u UrlConfig) fetchFile(ctx context.Context, c *Client, previous File) fetchedFile {
+	address := u.url()
+
+	resp, err := c.get(ctx, address, previous.ETag)
+	if err != nil {
+		return fetchedFile{err: u.fetchError(address, 0, err)}
+	}
+
+	var fetched fetchedFile
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		fetched, err = u.handleFile(resp)
+	case http.StatusNotModified:
+		resp.Body.Close()
+		fetched, err = parseFile(previous, "")
+	default:
+		resp.Body.Close()
+		return fetchedFile{err: u.fetchError(address, resp.StatusCode, nil)}
+	}
+
+	if err != nil {
+		return fetchedFile{err: u.fetchError(address, resp.StatusCode, err)}
+	}
+
+	return fetched
+}
+
+func (u UrlConfig) fetchError(address string, statusCode int, err error) *FetchError {
+	// the url is already part of the FetchError
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	return &FetchError{Path: u.Path, Url: address, StatusCode: statusCode, Err: err}
+}
+
+func (r *FetchResult) add(fetched fetchedFile) {
+	if fetched.err != nil {
+		fetched.err.Namespace = r.Namespace
+		r.Errors = append(r.Errors, fetched.err)
+		return
+	}
+
+	r.Protocols = append(r.Protocols, fetched.protocol)
+	r.Files = append(r.Files, fetched.file)
+	if r.Commit == "" {
+		r.Commit = fetched.commit
+	}
+}
+
+func (u UrlConfig) handleFile(resp *http.Response) (fetchedFile, error) {
 	defer resp.Body.Close()
 
 	var fileResp fileResponse
 	if err := json.NewDecoder(resp.Body).Decode(&fileResp); err != nil {
-		return xmlparser.Protocol{}, err
+		return fetchedFile{}, err
 	}
 
 	decodedContent, err := base64.StdEncoding.DecodeString(fileResp.Content)
 	if err != nil {
-		return xmlparser.Protocol{}, err
+		return fetchedFile{}, err
+	}
+
+	file := File{Path: u.Path, Content: decodedContent, ETag: resp.Header.Get("ETag")}
+
+	return parseFile(file, fileResp.CommitID)
+}
+
+func parseFile(file File, commit string) (fetchedFile, error) {
+	protocol, err := xmlparser.ParseProtocolFile(file.Path, file.Content)
+	if err != nil {
+		return fetchedFile{}, err
 	}
 
-	return xmlparser.ParseProtocol(decodedContent), nil
+	return fetchedFile{protocol: protocol, file: file, commit: commit}, nil
 }
 
 func (u UrlConfig) handleTree(resp *http.Response) ([]string, error) {
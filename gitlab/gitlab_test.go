@@ -0,0 +1,339 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testProtocol = `<protocol name="test"><interface name="test_a" version="1"/></protocol>`
+
+// fakeGitLab serves a repository of protocol files like the GitLab API,
+// answering file requests with 304 when their ETag matches.
+type fakeGitLab struct {
+	head  string
+	files map[string]string // content by path
+	etags map[string]string // by path
+
+	mu       sync.Mutex
+	requests []string // escaped paths after /repository/
+}
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path, ok := strings.CutPrefix(r.URL.EscapedPath(), "/api/v4/projects/group%2Frepo/repository/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, path)
+	f.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(path, "branches/"):
+		json.NewEncoder(w).Encode(map[string]any{"commit": map[string]string{"id": f.head}})
+
+	case path == "tree":
+		var nodes []treeResponse
+		for path := range f.files {
+			nodes = append(nodes, treeResponse{Path: path, Type: "blob"})
+		}
+		json.NewEncoder(w).Encode(nodes)
+
+	case strings.HasPrefix(path, "files/"):
+		file := strings.ReplaceAll(strings.TrimPrefix(path, "files/"), "%2F", "/")
+
+		content, ok := f.files[file]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("ETag", f.etags[file])
+		if r.Header.Get("If-None-Match") == f.etags[file] {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		json.NewEncoder(w).Encode(fileResponse{
+			Content:  base64.StdEncoding.EncodeToString([]byte(content)),
+			CommitID: f.head,
+		})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeGitLab) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = nil
+}
+
+// count returns the number of requests whose path starts with prefix.
+func (f *fakeGitLab) count(prefix string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, path := range f.requests {
+		if strings.HasPrefix(path, prefix) {
+			n++
+		}
+	}
+
+	return n
+}
+
+func newFakeGitLab(t *testing.T) (*fakeGitLab, UrlConfig) {
+	f := &fakeGitLab{
+		head:  "1111111111111111111111111111111111111111",
+		files: map[string]string{"protocols/test.xml": testProtocol},
+		etags: map[string]string{"protocols/test.xml": `W/"1"`},
+	}
+
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	return f, UrlConfig{
+		Origin:     server.URL,
+		Namespace:  "group",
+		Repository: "repo",
+		Branch:     "main",
+		Path:       "protocols",
+		UrlType:    UrlTypeTree,
+	}
+}
+
+func fetch(u UrlConfig, previous FetchResult) FetchResult {
+	var wg sync.WaitGroup
+	ch := make(chan FetchResult, 1)
+
+	wg.Add(1)
+	u.Fetch(context.Background(), NewClient(5*time.Second, 4), &wg, ch, "test", previous)
+
+	return <-ch
+}
+
+func TestFetchSetsCommit(t *testing.T) {
+	f, u := newFakeGitLab(t)
+
+	result := fetch(u, FetchResult{})
+	if len(result.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", result.Errors)
+	}
+
+	if result.Commit != f.head {
+		t.Errorf("got commit %q, want %q", result.Commit, f.head)
+	}
+
+	if len(result.Protocols) != 1 || len(result.Files) != 1 || result.Files[0].ETag != `W/"1"` {
+		t.Errorf("got protocols %v and files %v, want test.xml with its ETag", result.Protocols, result.Files)
+	}
+}
+
+func TestFetchSkipsUnchangedHead(t *testing.T) {
+	f, u := newFakeGitLab(t)
+
+	previous := fetch(u, FetchResult{})
+	f.reset()
+
+	result := fetch(u, previous)
+	if len(result.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", result.Errors)
+	}
+
+	if n := f.count("branches/"); n != 1 {
+		t.Errorf("got %d branch requests, want 1", n)
+	}
+
+	if n := f.count(""); n != 1 {
+		t.Errorf("got %d requests, want only the branch", n)
+	}
+
+	if result.Commit != f.head || len(result.Protocols) != 1 || result.Protocols[0].Name != "test" {
+		t.Errorf("got commit %q and protocols %v, want the previous ones", result.Commit, result.Protocols)
+	}
+}
+
+func TestFetchReusesNotModifiedFile(t *testing.T) {
+	f, u := newFakeGitLab(t)
+
+	previous := fetch(u, FetchResult{})
+
+	// a new commit, the content served for the unchanged ETag is only sent
+	// if the 304 is ignored
+	f.head = "2222222222222222222222222222222222222222"
+	f.files["protocols/test.xml"] = `<protocol name="changed"/>`
+	f.reset()
+
+	result := fetch(u, previous)
+	if len(result.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", result.Errors)
+	}
+
+	if n := f.count("files/"); n != 1 {
+		t.Errorf("got %d file requests, want 1", n)
+	}
+
+	if len(result.Files) != 1 || string(result.Files[0].Content) != testProtocol || result.Files[0].ETag != `W/"1"` {
+		t.Errorf("got files %v, want the previous content and ETag", result.Files)
+	}
+
+	if len(result.Protocols) != 1 || result.Protocols[0].Name != "test" {
+		t.Errorf("got protocols %v, want test", result.Protocols)
+	}
+
+	// the file answered 304 without a commit, the head of the branch is kept
+	if result.Commit != f.head {
+		t.Errorf("got commit %q, want %q", result.Commit, f.head)
+	}
+}
+
+// pagedTree serves a tree listing split in pages, linking each page to the
+// next with next.
+type pagedTree struct {
+	pages [][]string
+	next  func(w http.ResponseWriter, r *http.Request, page int)
+
+	mu       sync.Mutex
+	requests int
+}
+
+func (p *pagedTree) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.requests++
+	p.mu.Unlock()
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		page = 1
+	}
+
+	if page < 1 || page > len(p.pages) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if page < len(p.pages) {
+		p.next(w, r, page+1)
+	}
+
+	var nodes []treeResponse
+	for _, path := range p.pages[page-1] {
+		nodes = append(nodes, treeResponse{Path: path, Type: "blob"})
+	}
+	json.NewEncoder(w).Encode(nodes)
+}
+
+func TestFetchTreePages(t *testing.T) {
+	pages := [][]string{
+		{"protocols/a.xml", "protocols/b.xml"},
+		{"protocols/c.xml"},
+		{"protocols/d.xml"},
+	}
+
+	tests := []struct {
+		name string
+		next func(w http.ResponseWriter, r *http.Request, page int)
+	}{
+		{"X-Next-Page", func(w http.ResponseWriter, r *http.Request, page int) {
+			w.Header().Set("X-Next-Page", strconv.Itoa(page))
+		}},
+		{"Link", func(w http.ResponseWriter, r *http.Request, page int) {
+			// keyset pagination gives no page number, only links
+			next := fmt.Sprintf("http://%s%s?page=%d", r.Host, r.URL.EscapedPath(), page)
+			first := fmt.Sprintf("http://%s%s", r.Host, r.URL.EscapedPath())
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="first", <%s>; rel="next"`, first, next))
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree := &pagedTree{pages: pages, next: test.next}
+
+			server := httptest.NewServer(tree)
+			t.Cleanup(server.Close)
+
+			u := UrlConfig{Origin: server.URL, Namespace: "group", Repository: "repo", Branch: "main", Path: "protocols"}
+
+			paths, err := u.fetchTree(context.Background(), NewClient(5*time.Second, 4))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := slices.Concat(pages...)
+			if !slices.Equal(paths, want) {
+				t.Errorf("got paths %v, want %v", paths, want)
+			}
+
+			if tree.requests != len(pages) {
+				t.Errorf("got %d requests, want one per page", tree.requests)
+			}
+		})
+	}
+}
+
+func TestFetchTreeRepeatedPage(t *testing.T) {
+	// a server always giving the first page as the next one
+	tree := &pagedTree{
+		pages: [][]string{{"protocols/a.xml"}, {"protocols/b.xml"}},
+		next: func(w http.ResponseWriter, r *http.Request, page int) {
+			w.Header().Set("X-Next-Page", "1")
+		},
+	}
+
+	server := httptest.NewServer(tree)
+	t.Cleanup(server.Close)
+
+	u := UrlConfig{Origin: server.URL, Namespace: "group", Repository: "repo", Branch: "main", Path: "protocols"}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		if _, err := u.fetchTree(context.Background(), NewClient(5*time.Second, 4)); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetchTree kept requesting the same page")
+	}
+
+	// the first address has no page parameter, the one of page 1 is only
+	// requested once
+	if tree.requests > 2 {
+		t.Errorf("got %d requests, want at most 2", tree.requests)
+	}
+}
+
+func TestFetchReportsUnparsableReusedFile(t *testing.T) {
+	f, u := newFakeGitLab(t)
+
+	previous := fetch(u, FetchResult{})
+	previous.Files = append(previous.Files, File{Path: "protocols/broken.xml", Content: []byte("<protocol")})
+
+	result := fetch(u, previous)
+	if len(result.Errors) != 1 || result.Errors[0].Path != "protocols/broken.xml" || result.Errors[0].Namespace != "test" {
+		t.Fatalf("got errors %v, want one for broken.xml", result.Errors)
+	}
+
+	if result.Commit != f.head || len(result.Protocols) != 1 || result.Protocols[0].Name != "test" {
+		t.Errorf("got commit %q and protocols %v, want the parsable previous ones", result.Commit, result.Protocols)
+	}
+}
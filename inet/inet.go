@@ -1,79 +1,100 @@
 package inet
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
+	"wlpv/cache"
 	"wlpv/gitlab"
 	"wlpv/xmlparser"
 )
 
-func GetProtocolContents() (map[string][]xmlparser.Protocol, error) {
-	var wg sync.WaitGroup
+// refreshes tracks the background refreshes of stale cache entries, which
+// are aborted once refreshContext is cancelled.
+var (
+	refreshes                      sync.WaitGroup
+	refreshContext, stopRefreshing = context.WithCancel(context.Background())
+)
 
-	ch := make(chan gitlab.FetchResult)
+// Report is the outcome of fetching namespaces.
+type Report struct {
+	Revisions map[string]gitlab.Revision      // what each namespace was fetched from
+	Errors    map[string][]*gitlab.FetchError // failed requests of each failed or incomplete namespace
+	failed    map[string]bool
+}
+
+func (r *Report) add(result gitlab.FetchResult) {
+	r.addErrors(result.Namespace, result.Errors, result.Failed())
+}
+
+// addErrors records the errors of a namespace, failed if none of its
+// protocols could be read.
+func (r *Report) addErrors(namespace string, errs []*gitlab.FetchError, failed bool) {
+	if len(errs) == 0 {
+		return
+	}
+
+	if r.Errors == nil {
+		r.Errors = make(map[string][]*gitlab.FetchError)
+		r.failed = make(map[string]bool)
+	}
+
+	for _, err := range errs {
+		err.Namespace = namespace
+	}
+
+	r.Errors[namespace] = errs
+	r.failed[namespace] = failed
+}
+
+// addEntry records the revision of a cache entry and the files of it that
+// fail to parse, and returns its protocols.
+func (r *Report) addEntry(namespace string, uc gitlab.UrlConfig, entry cache.Entry) []xmlparser.Protocol {
+	protocols, errs := entry.Protocols()
+	r.addErrors(namespace, errs, len(protocols) == 0)
+	r.Revisions[namespace] = gitlab.Revision{Repository: uc.Repository, Commit: entry.Commit, FetchedAt: entry.FetchedAt}
+
+	return protocols
+}
+
+// Failed returns the namespaces of which nothing could be fetched, sorted.
+func (r Report) Failed() []string {
+	return r.namespaces(true)
+}
 
-	urls := map[string]gitlab.UrlConfig{
-		"core": {
-			Origin:     "https://gitlab.freedesktop.org",
-			Namespace:  "wayland",
-			Repository: "wayland",
-			Branch:     "main",
-			UrlType:    gitlab.UrlTypeFiles,
-			Path:       "protocol/wayland.xml",
-		},
-		"stable": {
-			Origin:     "https://gitlab.freedesktop.org",
-			Namespace:  "wayland",
-			Repository: "wayland-protocols",
-			Branch:     "main",
-			UrlType:    gitlab.UrlTypeTree,
-			Path:       "stable",
-		},
-		"staging": {
-			Origin:     "https://gitlab.freedesktop.org",
-			Namespace:  "wayland",
-			Repository: "wayland-protocols",
-			Branch:     "main",
-			UrlType:    gitlab.UrlTypeTree,
-			Path:       "staging",
-		},
-		"unstable": {
-			Origin:     "https://gitlab.freedesktop.org",
-			Namespace:  "wayland",
-			Repository: "wayland-protocols",
-			Branch:     "main",
-			UrlType:    gitlab.UrlTypeTree,
-			Path:       "unstable",
-		},
-		"wlroots": {
-			Origin:     "https://gitlab.freedesktop.org",
-			Namespace:  "wlroots",
-			Repository: "wlr-protocols",
-			Branch:     "master",
-			UrlType:    gitlab.UrlTypeTree,
-			Path:       "unstable",
-		},
-		"weston": {
-			Origin:     "https://gitlab.freedesktop.org",
-			Namespace:  "wayland",
-			Repository: "weston",
-			Branch:     "main",
-			UrlType:    gitlab.UrlTypeTree,
-			Path:       "protocol",
-		},
-		"kde": {
-			Origin:     "https://invent.kde.org",
-			Namespace:  "libraries",
-			Repository: "plasma-wayland-protocols",
-			Branch:     "master",
-			UrlType:    gitlab.UrlTypeTree,
-			Path:       "src/protocols",
-		},
+// Incomplete returns the namespaces of which some files could not be
+// fetched, sorted.
+func (r Report) Incomplete() []string {
+	return r.namespaces(false)
+}
+
+func (r Report) namespaces(failed bool) []string {
+	var namespaces []string
+	for namespace := range r.Errors {
+		if r.failed[namespace] == failed {
+			namespaces = append(namespaces, namespace)
+		}
 	}
 
+	sort.Strings(namespaces)
+
+	return namespaces
+}
+
+// fetchAll fetches the namespaces concurrently with client, reusing what is
+// unchanged since their previous fetch if any. The channel is closed once
+// every fetch is done.
+func fetchAll(ctx context.Context, client *gitlab.Client, urls map[string]gitlab.UrlConfig, previous map[string]gitlab.FetchResult) <-chan gitlab.FetchResult {
+	var wg sync.WaitGroup
+
+	ch := make(chan gitlab.FetchResult)
+
 	for ns, uc := range urls {
 		wg.Add(1)
-		go uc.Fetch(&wg, ch, ns)
+		go uc.Fetch(ctx, client, &wg, ch, ns, previous[ns])
 	}
 
 	go func() {
@@ -81,14 +102,158 @@ func GetProtocolContents() (map[string][]xmlparser.Protocol, error) {
 		close(ch)
 	}()
 
+	return ch
+}
+
+// GetProtocolContents returns the protocols of each namespace, and reports
+// the revision they were fetched from and the namespaces that failed.
+// Namespaces found in the cache are returned as cached, and fetched again in
+// the background if their entry is stale. The others are fetched from their
+// repository until ctx is done, and stored in the cache, which may be nil.
+func GetProtocolContents(ctx context.Context, client *gitlab.Client, urls map[string]gitlab.UrlConfig, c *cache.Cache) (map[string][]xmlparser.Protocol, Report, error) {
 	protocols := make(map[string][]xmlparser.Protocol)
-	for fetchResult := range ch {
+	report := Report{Revisions: make(map[string]gitlab.Revision)}
+	missing := make(map[string]gitlab.UrlConfig)
+	stale := make(map[string]gitlab.UrlConfig)
+	previous := make(map[string]gitlab.FetchResult)
+
+	for ns, uc := range urls {
+		entry, ok := c.Load(uc)
+		if !ok {
+			missing[ns] = uc
+			continue
+		}
+
+		protocols[ns] = report.addEntry(ns, uc, entry)
+		if c.Stale(entry) {
+			stale[ns] = uc
+			previous[ns] = entry.Result()
+		}
+	}
+
+	for fetchResult := range fetchAll(ctx, client, missing, nil) {
+		report.add(fetchResult)
+		if fetchResult.Failed() {
+			continue
+		}
+
+		uc := missing[fetchResult.Namespace]
+
 		protocols[fetchResult.Namespace] = fetchResult.Protocols
+		report.Revisions[fetchResult.Namespace] = gitlab.Revision{Repository: uc.Repository, Commit: fetchResult.Commit, FetchedAt: time.Now()}
+		store(c, uc, fetchResult)
+	}
+
+	if len(stale) > 0 {
+		refreshes.Add(1)
+		go func() {
+			defer refreshes.Done()
+
+			// a failed refresh keeps the stale entry
+			for fetchResult := range fetchAll(refreshContext, client, stale, previous) {
+				store(c, stale[fetchResult.Namespace], fetchResult)
+			}
+		}()
 	}
 
 	if len(protocols) == 0 {
-		return nil, fmt.Errorf("fetch failed or returned no results")
+		return nil, report, fmt.Errorf("fetch failed or returned no results")
+	}
+
+	return protocols, report, nil
+}
+
+// GetCachedContents returns the protocols of the namespaces found in the
+// cache, stale or not, without fetching anything. The report has their
+// revisions and the cached files that fail to parse.
+func GetCachedContents(urls map[string]gitlab.UrlConfig, c *cache.Cache) (map[string][]xmlparser.Protocol, Report) {
+	protocols := make(map[string][]xmlparser.Protocol)
+	report := Report{Revisions: make(map[string]gitlab.Revision)}
+
+	for ns, uc := range urls {
+		if entry, ok := c.Load(uc); ok {
+			protocols[ns] = report.addEntry(ns, uc, entry)
+		}
+	}
+
+	return protocols, report
+}
+
+// WaitRefreshes waits for the background refreshes of the cache started by
+// GetProtocolContents.
+func WaitRefreshes() {
+	refreshes.Wait()
+}
+
+// StopRefreshes aborts the background refreshes of the cache, and waits for
+// them to return. The stale entries are kept.
+func StopRefreshes() {
+	stopRefreshing()
+	refreshes.Wait()
+}
+
+// store caches a complete result, the files missing from an incomplete one
+// are then fetched again next time.
+func store(c *cache.Cache, uc gitlab.UrlConfig, result gitlab.FetchResult) {
+	if len(result.Files) == 0 || len(result.Errors) > 0 {
+		return
+	}
+
+	// the fetched protocols are used anyway, a cache that cannot be written
+	// only costs fetching them again next time
+	c.Store(uc, cache.NewEntry(uc, result))
+}
+
+// GetNamespaceContents returns the protocols of a namespace at a branch or
+// tag of its repository instead of the configured branch. A fresh cache
+// entry is used as is, a stale one only if fetching fails. Fetching fails if
+// any file cannot be fetched, rather than returning some of the protocols.
+func GetNamespaceContents(ctx context.Context, client *gitlab.Client, namespace string, uc gitlab.UrlConfig, ref string, c *cache.Cache) ([]xmlparser.Protocol, error) {
+	uc.Branch = ref
+
+	entry, cached := c.Load(uc)
+	if cached && !c.Stale(entry) {
+		return cachedProtocols(namespace, ref, entry)
+	}
+
+	var previous map[string]gitlab.FetchResult
+	if cached {
+		previous = map[string]gitlab.FetchResult{namespace: entry.Result()}
+	}
+
+	result := <-fetchAll(ctx, client, map[string]gitlab.UrlConfig{namespace: uc}, previous)
+	if len(result.Errors) == 0 && len(result.Protocols) > 0 {
+		store(c, uc, result)
+		return result.Protocols, nil
+	}
+
+	if cached {
+		return cachedProtocols(namespace, ref, entry)
+	}
+
+	if len(result.Errors) > 0 {
+		return nil, fetchFailed(namespace, ref, result.Errors)
+	}
+
+	return nil, fmt.Errorf("fetching %s at %q returned no results", namespace, ref)
+}
+
+// cachedProtocols returns the protocols of a cache entry, which fails like a
+// fetch if any of its files cannot be parsed.
+func cachedProtocols(namespace, ref string, entry cache.Entry) ([]xmlparser.Protocol, error) {
+	protocols, errs := entry.Protocols()
+	if len(errs) > 0 {
+		return nil, fetchFailed(namespace, ref, errs)
 	}
 
 	return protocols, nil
 }
+
+func fetchFailed(namespace, ref string, fetchErrs []*gitlab.FetchError) error {
+	errs := make([]error, len(fetchErrs))
+	for i, err := range fetchErrs {
+		errs[i] = err
+	}
+
+	return fmt.Errorf("fetching %s at %q failed: %w", namespace, ref, errors.Join(errs...))
+}
@@ -1,10 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"wlpv/cache"
 	"wlpv/cli"
+	"wlpv/config"
+	"wlpv/gitlab"
 	"wlpv/inet"
 	"wlpv/offline"
 	"wlpv/tui"
@@ -14,6 +23,7 @@ import (
 func main() {
 	opts, err := cli.ParseArguments()
 	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -27,32 +37,204 @@ func main() {
 		os.Exit(0)
 	}
 
+	if opts.Command != "" {
+		code := runCommand(opts)
+
+		// commands are short-lived, wait for them to refresh stale cached
+		// protocols so that the next run gets them
+		inet.WaitRefreshes()
+		os.Exit(code)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	protocols, report, err := loadProtocols(opts.Offline, opts.Additions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	revisions := make(map[string]tui.Revision)
+	for namespace, revision := range report.Revisions {
+		revisions[namespace] = tui.Revision{
+			Repository: revision.Repository,
+			Commit:     revision.Commit,
+			FetchedAt:  revision.FetchedAt,
+		}
+	}
+
+	err = tui.Run(opts.Protocol, protocols, revisions, report.Failed(), report.Incomplete(), cfg.Namespaces())
+
+	// quitting aborts the refreshes of stale cached protocols
+	inet.StopRefreshes()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+func runCommand(opts cli.Options) int {
+	switch opts.Command {
+	case cli.CommandValidate:
+		return runValidate(opts.Validate)
+	case cli.CommandExport:
+		return runExport(opts.Export)
+	case cli.CommandServe:
+		return runServe(opts.Serve)
+	case cli.CommandDump:
+		return runDump(opts.Dump)
+	case cli.CommandTrace:
+		return runTrace(opts.Trace)
+	case cli.CommandDecode:
+		return runDecode(opts.Decode)
+	case cli.CommandGen:
+		return runGen(opts.Gen)
+	case cli.CommandDiff:
+		return runDiff(opts.Diff)
+	case cli.CommandCompat:
+		return runCompat(opts.Compat)
+	case cli.CommandConfig:
+		return runConfig(opts.Config)
+	}
+
+	return 1
+}
+
+// loadConfig returns the configured sources, the config file is only read
+// once.
+var loadConfig = sync.OnceValues(config.Load)
+
+// errInterrupted is returned when fetching protocols is aborted with ^C.
+var errInterrupted = errors.New("interrupted")
+
+// loadProtocols returns the protocols of the configured sources, with the
+// additions under the "User" namespace, and the report of fetching the
+// namespaces from GitLab, a summary of which is printed if any failed. In
+// offline mode, these namespaces are instead read from the system if found
+// in /usr/share/*, else from the cache, and skipped with a warning if in
+// neither.
+func loadProtocols(offlineMode bool, additions []xmlparser.Protocol) (map[string][]xmlparser.Protocol, inet.Report, error) {
+	var report inet.Report
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, report, err
+	}
+
 	protocols := make(map[string][]xmlparser.Protocol)
-	protocols["User"] = opts.Additions
+	protocols["User"] = additions
 
-	if opts.Offline {
-		protocolsFromSystem, err := offline.GetProtocolContents()
-		if err != nil {
-			os.Exit(1)
+	urls := make(map[string]gitlab.UrlConfig)
+	for _, source := range cfg.Sources {
+		if source.Type == config.SourceGitLab {
+			urls[source.Namespace] = source.GitLab
+		}
+	}
+
+	if len(urls) > 0 {
+		if offlineMode {
+			protocolsFromSystem, err := offline.GetProtocolContents()
+			if err != nil {
+				return nil, report, err
+			}
+
+			uninstalled := make(map[string]gitlab.UrlConfig)
+			for namespace, uc := range urls {
+				if protocolGroup := protocolsFromSystem[namespace]; len(protocolGroup) > 0 {
+					protocols[namespace] = protocolGroup
+				} else {
+					uninstalled[namespace] = uc
+				}
+			}
+
+			c, _ := cache.Open(cfg.CacheTTL)
+			protocolsFromCache, cacheReport := inet.GetCachedContents(uninstalled, c)
+			printReport(cacheReport)
+			report = cacheReport
+
+			var skipped []string
+			for namespace := range uninstalled {
+				if protocolGroup, ok := protocolsFromCache[namespace]; ok {
+					protocols[namespace] = protocolGroup
+				} else {
+					skipped = append(skipped, namespace)
+				}
+			}
+
+			if len(skipped) > 0 {
+				sort.Strings(skipped)
+				fmt.Fprintf(os.Stderr, "warning: skipping %s, neither installed nor cached\n", strings.Join(skipped, ", "))
+			}
+		} else {
+			// without a cache directory, protocols are always fetched
+			c, _ := cache.Open(cfg.CacheTTL)
+			client := gitlab.NewClient(cfg.Timeout, cfg.MaxRequests)
+
+			// ^C aborts fetching
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			protocolsFromNet, fetchReport, err := inet.GetProtocolContents(ctx, client, urls, c)
+			if ctx.Err() != nil {
+				return nil, report, errInterrupted
+			}
+
+			printReport(fetchReport)
+			if err != nil {
+				return nil, fetchReport, err
+			}
+
+			report = fetchReport
+
+			for namespace, protocolGroup := range protocolsFromNet {
+				protocols[namespace] = protocolGroup
+			}
 		}
+	}
 
-		for namespace, protocolGroup := range protocolsFromSystem {
-			protocols[namespace] = protocolGroup
+	for _, source := range cfg.Sources {
+		var protocolGroup []xmlparser.Protocol
+
+		switch source.Type {
+		case config.SourceDir:
+			protocolGroup, err = offline.GetDirContents(source.Path)
+		case config.SourceFile:
+			protocolGroup, err = offline.GetFileContents(source.Path)
+		default:
+			continue
 		}
-	} else {
-		protocolsFromNet, err := inet.GetProtocolContents()
+
 		if err != nil {
-			os.Exit(1)
+			return nil, report, err
 		}
 
-		for namespace, protocolGroup := range protocolsFromNet {
-			protocols[namespace] = protocolGroup
-		}
+		protocols[source.Namespace] = protocolGroup
 	}
 
-	if err := tui.Run(opts.Protocol, protocols); err != nil {
-		os.Exit(1)
+	return protocols, report, nil
+}
+
+// printReport prints the failed requests and unparsable cached files of the
+// namespaces that could not be fetched, entirely or in part.
+func printReport(report inet.Report) {
+	for _, namespace := range report.Failed() {
+		fmt.Fprintf(os.Stderr, "warning: fetching %s failed:\n", namespace)
+		for _, err := range report.Errors[namespace] {
+			fmt.Fprintf(os.Stderr, "    %v\n", err)
+		}
 	}
 
-	os.Exit(0)
+	for _, namespace := range report.Incomplete() {
+		fmt.Fprintf(os.Stderr, "warning: fetching %s is incomplete, %d file(s) missing:\n", namespace, len(report.Errors[namespace]))
+		for _, err := range report.Errors[namespace] {
+			fmt.Fprintf(os.Stderr, "    %v\n", err)
+		}
+	}
 }
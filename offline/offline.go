@@ -1,6 +1,8 @@
 package offline
 
 import (
+	"fmt"
+	"os"
 	"wlpv/util"
 	"wlpv/xmlparser"
 )
@@ -14,52 +16,75 @@ const wlr = "wlr-protocols"
 const kde = "plasma-wayland-protocols"
 const weston = "libweston*"
 
-func getProtocolsInDir(path string) ([]xmlparser.Protocol, error) {
+// GetDirContents reads every protocol of a directory and its
+// subdirectories, files that fail to parse are skipped with a warning.
+func GetDirContents(path string) ([]xmlparser.Protocol, error) {
 	files, err := util.AllFilesInDir(path, ".xml")
 	if err != nil {
 		return nil, err
 	}
 
-	contents, err := util.ReadAllFiles(files)
+	var protocols []xmlparser.Protocol
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		protocol, err := xmlparser.ParseProtocolFile(file, content)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping %v\n", err)
+			continue
+		}
+
+		protocols = append(protocols, protocol)
+	}
+
+	return protocols, nil
+}
+
+// GetFileContents reads a single protocol file.
+func GetFileContents(path string) ([]xmlparser.Protocol, error) {
+	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var protocols []xmlparser.Protocol
-	for _, content := range contents {
-		protocols = append(protocols, xmlparser.ParseProtocol(content))
+	protocol, err := xmlparser.ParseProtocolFile(path, content)
+	if err != nil {
+		return nil, err
 	}
 
-	return protocols, nil
+	return []xmlparser.Protocol{protocol}, nil
 }
 
 func GetProtocolContents() (map[string][]xmlparser.Protocol, error) {
-	waylandProtocols, err := getProtocolsInDir(usrshare + wl) // length should only be 1 (only wayland.xml)
+	waylandProtocols, err := GetDirContents(usrshare + wl) // length should only be 1 (only wayland.xml)
 	if err != nil {
 		return nil, err
 	}
 
-	stableProtocols, err := getProtocolsInDir(usrshare + stable)
+	stableProtocols, err := GetDirContents(usrshare + stable)
 	if err != nil {
 		return nil, err
 	}
 
-	stagingProtocols, err := getProtocolsInDir(usrshare + staging)
+	stagingProtocols, err := GetDirContents(usrshare + staging)
 	if err != nil {
 		return nil, err
 	}
 
-	unstableProtocols, err := getProtocolsInDir(usrshare + unstable)
+	unstableProtocols, err := GetDirContents(usrshare + unstable)
 	if err != nil {
 		return nil, err
 	}
 
-	wlrProtocols, err := getProtocolsInDir(usrshare + wlr)
+	wlrProtocols, err := GetDirContents(usrshare + wlr)
 	if err != nil {
 		return nil, err
 	}
 
-	kdeProtocols, err := getProtocolsInDir(usrshare + kde)
+	kdeProtocols, err := GetDirContents(usrshare + kde)
 	if err != nil {
 		return nil, err
 	}
@@ -69,7 +94,7 @@ func GetProtocolContents() (map[string][]xmlparser.Protocol, error) {
 		return nil, err
 	}
 
-	westonProtocols, err := getProtocolsInDir(westonPathMatches[0])
+	westonProtocols, err := GetDirContents(westonPathMatches[0])
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,139 @@
+package resolver
+
+import (
+	"sort"
+	"strings"
+	"wlpv/xmlparser"
+)
+
+type Kind uint8
+
+const (
+	KindInterface Kind = iota
+	KindEnum
+)
+
+// Location identifies an interface, or an enum when Enum is set, within the
+// loaded protocols.
+type Location struct {
+	Namespace string
+	Protocol  string
+	Interface string
+	Enum      string
+}
+
+func (l Location) Kind() Kind {
+	if l.Enum != "" {
+		return KindEnum
+	}
+
+	return KindInterface
+}
+
+func (l Location) String() string {
+	if l.Enum != "" {
+		return l.Interface + "." + l.Enum
+	}
+
+	return l.Interface
+}
+
+// Index maps interface and enum names to their definitions across every
+// namespace. A name defined more than once resolves to the definition
+// closest to where it is referenced from.
+type Index struct {
+	interfaces map[string][]Location
+	enums      map[string][]Location // keyed by "interface.enum"
+}
+
+func New(protocols map[string][]xmlparser.Protocol) *Index {
+	idx := &Index{
+		interfaces: make(map[string][]Location),
+		enums:      make(map[string][]Location),
+	}
+
+	namespaces := make([]string, 0, len(protocols))
+	for namespace := range protocols {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	for _, namespace := range namespaces {
+		for _, protocol := range protocols[namespace] {
+			idx.Add(namespace, protocol)
+		}
+	}
+
+	return idx
+}
+
+func (idx *Index) Add(namespace string, protocol xmlparser.Protocol) {
+	for _, iface := range protocol.Interfaces {
+		location := Location{
+			Namespace: namespace,
+			Protocol:  protocol.Name,
+			Interface: iface.Name,
+		}
+		idx.interfaces[iface.Name] = append(idx.interfaces[iface.Name], location)
+
+		for _, enum := range iface.Enums {
+			location.Enum = enum.Name
+			key := iface.Name + "." + enum.Name
+			idx.enums[key] = append(idx.enums[key], location)
+		}
+	}
+}
+
+func closest(candidates []Location, from Location) (Location, bool) {
+	if len(candidates) == 0 {
+		return Location{}, false
+	}
+
+	best := candidates[0]
+	bestScore := -1
+
+	for _, candidate := range candidates {
+		score := 0
+		if candidate.Namespace == from.Namespace {
+			score++
+			if candidate.Protocol == from.Protocol {
+				score++
+			}
+		}
+
+		if score > bestScore {
+			best = candidate
+			bestScore = score
+		}
+	}
+
+	return best, true
+}
+
+// Interface resolves an interface name as referenced from the given location.
+func (idx *Index) Interface(name string, from Location) (Location, bool) {
+	return closest(idx.interfaces[name], from)
+}
+
+// Enum resolves the value of an enum attribute as referenced from the given
+// location, either "enum" within from.Interface or "interface.enum".
+func (idx *Index) Enum(ref string, from Location) (Location, bool) {
+	if !strings.Contains(ref, ".") {
+		ref = from.Interface + "." + ref
+	}
+
+	return closest(idx.enums[ref], from)
+}
+
+// Argument resolves the interface or enum an argument refers to.
+func (idx *Index) Argument(arg xmlparser.Argument, from Location) (Location, bool) {
+	if arg.Interface != "" {
+		return idx.Interface(arg.Interface, from)
+	}
+
+	if arg.Enum != "" {
+		return idx.Enum(arg.Enum, from)
+	}
+
+	return Location{}, false
+}
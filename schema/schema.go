@@ -0,0 +1,243 @@
+// Package schema is the JSON representation of parsed protocols. Within a
+// schema version fields are only ever added, removing or changing the
+// meaning of a field bumps Version.
+package schema
+
+import (
+	"sort"
+	"strconv"
+	"wlpv/xmlparser"
+)
+
+// Version of the schema, written as schema_version in a Document.
+const Version = 1
+
+// Document is the top level object of a dump.
+type Document struct {
+	SchemaVersion int        `json:"schema_version"`
+	Protocols     []Protocol `json:"protocols"`
+}
+
+type Protocol struct {
+	Namespace   string      `json:"namespace"`
+	Name        string      `json:"name"`
+	Summary     string      `json:"summary,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Copyright   string      `json:"copyright,omitempty"`
+	Interfaces  []Interface `json:"interfaces"`
+}
+
+type Interface struct {
+	Name        string    `json:"name"`
+	Version     int       `json:"version"`
+	Summary     string    `json:"summary,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Requests    []Message `json:"requests"`
+	Events      []Message `json:"events"`
+	Enums       []Enum    `json:"enums"`
+}
+
+// Message is a request or an event, Opcode is its index among the requests
+// or the events of its interface, as sent on the wire.
+type Message struct {
+	Name            string     `json:"name"`
+	Opcode          int        `json:"opcode"`
+	Destructor      bool       `json:"destructor,omitempty"`
+	Since           int        `json:"since"`
+	DeprecatedSince int        `json:"deprecated_since,omitempty"`
+	Summary         string     `json:"summary,omitempty"`
+	Description     string     `json:"description,omitempty"`
+	Args            []Argument `json:"args"`
+}
+
+type Argument struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Interface string `json:"interface,omitempty"`
+	Enum      string `json:"enum,omitempty"`
+	AllowNull bool   `json:"allow_null,omitempty"`
+	Since     int    `json:"since,omitempty"`
+	Summary   string `json:"summary,omitempty"`
+}
+
+type Enum struct {
+	Name        string  `json:"name"`
+	Bitfield    bool    `json:"bitfield,omitempty"`
+	Since       int     `json:"since"`
+	Summary     string  `json:"summary,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Entries     []Entry `json:"entries"`
+}
+
+// Entry is an enum entry, Value is the literal of the protocol file and
+// Number its integer value, missing if the literal is invalid.
+type Entry struct {
+	Name    string  `json:"name"`
+	Value   string  `json:"value"`
+	Number  *uint32 `json:"number,omitempty"`
+	Since   int     `json:"since,omitempty"`
+	Summary string  `json:"summary,omitempty"`
+}
+
+// ProtocolSummary is the short form of a protocol used in listings.
+type ProtocolSummary struct {
+	Namespace  string   `json:"namespace"`
+	Name       string   `json:"name"`
+	Summary    string   `json:"summary,omitempty"`
+	Interfaces []string `json:"interfaces"`
+}
+
+// version converts a version attribute, which defaults to 1 when missing
+// or invalid.
+func version(s string) int {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 1 {
+		return 1
+	}
+
+	return v
+}
+
+// optionalVersion is like version, but returns 0 when s is empty.
+func optionalVersion(s string) int {
+	if s == "" {
+		return 0
+	}
+
+	return version(s)
+}
+
+func FromProtocol(namespace string, p xmlparser.Protocol) Protocol {
+	protocol := Protocol{
+		Namespace:   namespace,
+		Name:        p.Name,
+		Summary:     p.Description.Summary,
+		Description: p.Description.Text(),
+		Copyright:   (xmlparser.Description{Content: p.Copyright}).Text(),
+		Interfaces:  []Interface{},
+	}
+
+	for _, iface := range p.Interfaces {
+		protocol.Interfaces = append(protocol.Interfaces, fromInterface(iface))
+	}
+
+	return protocol
+}
+
+func fromInterface(i xmlparser.Interface) Interface {
+	iface := Interface{
+		Name:        i.Name,
+		Version:     version(i.Version),
+		Summary:     i.Description.Summary,
+		Description: i.Description.Text(),
+		Requests:    []Message{},
+		Events:      []Message{},
+		Enums:       []Enum{},
+	}
+
+	for _, request := range i.Requests {
+		iface.Requests = append(iface.Requests, fromMessage(request))
+	}
+
+	for _, event := range i.Events {
+		iface.Events = append(iface.Events, fromMessage(event))
+	}
+
+	for _, e := range i.Enums {
+		enum := Enum{
+			Name:        e.Name,
+			Bitfield:    e.Bitfield == "true",
+			Since:       version(e.Since),
+			Summary:     e.Description.Summary,
+			Description: e.Description.Text(),
+			Entries:     []Entry{},
+		}
+
+		for _, entry := range e.Entries {
+			en := Entry{
+				Name:    entry.Name,
+				Value:   entry.Value,
+				Since:   optionalVersion(entry.Since),
+				Summary: entry.Summary,
+			}
+
+			if entry.NumberValid {
+				number := entry.Number
+				en.Number = &number
+			}
+
+			enum.Entries = append(enum.Entries, en)
+		}
+
+		iface.Enums = append(iface.Enums, enum)
+	}
+
+	return iface
+}
+
+func fromMessage(m xmlparser.Message) Message {
+	message := Message{
+		Name:            m.Name,
+		Opcode:          m.Opcode,
+		Destructor:      m.Type == "destructor",
+		Since:           version(m.Since),
+		DeprecatedSince: optionalVersion(m.DeprecatedSince),
+		Summary:         m.Description.Summary,
+		Description:     m.Description.Text(),
+		Args:            []Argument{},
+	}
+
+	for _, arg := range m.Arguments {
+		message.Args = append(message.Args, Argument{
+			Name:      arg.Name,
+			Type:      arg.Type,
+			Interface: arg.Interface,
+			Enum:      arg.Enum,
+			AllowNull: arg.AllowNull == "true",
+			Since:     optionalVersion(arg.Since),
+			Summary:   arg.Summary,
+		})
+	}
+
+	return message
+}
+
+func Summarize(namespace string, p xmlparser.Protocol) ProtocolSummary {
+	summary := ProtocolSummary{
+		Namespace:  namespace,
+		Name:       p.Name,
+		Summary:    p.Description.Summary,
+		Interfaces: []string{},
+	}
+
+	for _, iface := range p.Interfaces {
+		summary.Interfaces = append(summary.Interfaces, iface.Name)
+	}
+
+	return summary
+}
+
+// NewDocument returns the document of the given protocols, ordered by
+// namespace and then protocol name.
+func NewDocument(protocols map[string][]xmlparser.Protocol) Document {
+	document := Document{SchemaVersion: Version, Protocols: []Protocol{}}
+
+	namespaces := make([]string, 0, len(protocols))
+	for namespace := range protocols {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	for _, namespace := range namespaces {
+		group := append([]xmlparser.Protocol(nil), protocols[namespace]...)
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Name < group[j].Name
+		})
+
+		for _, protocol := range group {
+			document.Protocols = append(document.Protocols, FromProtocol(namespace, protocol))
+		}
+	}
+
+	return document
+}
@@ -0,0 +1,278 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"wlpv/xmlparser"
+)
+
+// Document is a single searchable element of a protocol.
+type Document struct {
+	xmlparser.Element
+	Namespace string
+	Protocol  string
+	Text      string // summary and description
+}
+
+func (d Document) name() string {
+	switch {
+	case d.Child != "":
+		return d.Child
+	case d.Member != "":
+		return d.Member
+	case d.Interface != "":
+		return d.Interface
+	}
+
+	return d.Protocol
+}
+
+type Result struct {
+	Document
+	Context string // line of Text containing the first match, if any
+	score   int
+}
+
+type posting struct {
+	document int
+	inName   bool
+}
+
+// Index is an inverted index from lowercase words to the documents
+// containing them.
+type Index struct {
+	documents []Document
+	postings  map[string][]posting
+	words     []string // sorted keys of postings, for prefix lookups
+}
+
+func New(protocols map[string][]xmlparser.Protocol) *Index {
+	idx := &Index{postings: make(map[string][]posting)}
+
+	namespaces := make([]string, 0, len(protocols))
+	for namespace := range protocols {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	for _, namespace := range namespaces {
+		for _, protocol := range protocols[namespace] {
+			idx.addProtocol(namespace, protocol)
+		}
+	}
+
+	idx.words = make([]string, 0, len(idx.postings))
+	for word := range idx.postings {
+		idx.words = append(idx.words, word)
+	}
+	sort.Strings(idx.words)
+
+	return idx
+}
+
+func descriptionText(d xmlparser.Description) string {
+	if d.Summary == "" {
+		return strings.TrimSpace(d.Content)
+	}
+
+	return d.Summary + "\n" + strings.TrimSpace(d.Content)
+}
+
+func (idx *Index) addProtocol(namespace string, protocol xmlparser.Protocol) {
+	idx.add(Document{
+		Element:   xmlparser.Element{Kind: xmlparser.ElementProtocol},
+		Namespace: namespace,
+		Protocol:  protocol.Name,
+		Text:      descriptionText(protocol.Description),
+	})
+
+	for _, iface := range protocol.Interfaces {
+		doc := Document{
+			Element:   xmlparser.Element{Kind: xmlparser.ElementInterface, Interface: iface.Name},
+			Namespace: namespace,
+			Protocol:  protocol.Name,
+			Text:      descriptionText(iface.Description),
+		}
+		idx.add(doc)
+
+		for _, message := range iface.Messages() {
+			argKind := xmlparser.ElementRequestArgument
+			doc.Kind = xmlparser.ElementRequest
+			if message.Kind == xmlparser.MessageKindEvent {
+				argKind = xmlparser.ElementEventArgument
+				doc.Kind = xmlparser.ElementEvent
+			}
+			doc.Member = message.Name
+			doc.Child = ""
+			doc.Text = descriptionText(message.Description)
+			idx.add(doc)
+
+			for _, arg := range message.Arguments {
+				argDoc := doc
+				argDoc.Kind = argKind
+				argDoc.Child = arg.Name
+				argDoc.Text = strings.TrimSpace(arg.Summary + "\n" + descriptionText(arg.Description))
+				idx.add(argDoc)
+			}
+		}
+
+		for _, enum := range iface.Enums {
+			doc.Kind = xmlparser.ElementEnum
+			doc.Member = enum.Name
+			doc.Child = ""
+			doc.Text = descriptionText(enum.Description)
+			idx.add(doc)
+
+			for _, entry := range enum.Entries {
+				entryDoc := doc
+				entryDoc.Kind = xmlparser.ElementEntry
+				entryDoc.Child = entry.Name
+				entryDoc.Text = strings.TrimSpace(entry.Summary + "\n" + descriptionText(entry.Description))
+				idx.add(entryDoc)
+			}
+		}
+	}
+}
+
+func (idx *Index) add(doc Document) {
+	id := len(idx.documents)
+	idx.documents = append(idx.documents, doc)
+
+	seen := make(map[string]bool)
+	for _, word := range tokenize(doc.name(), true) {
+		if !seen[word] {
+			idx.postings[word] = append(idx.postings[word], posting{document: id, inName: true})
+			seen[word] = true
+		}
+	}
+
+	for _, word := range tokenize(doc.Text, true) {
+		if !seen[word] {
+			idx.postings[word] = append(idx.postings[word], posting{document: id})
+			seen[word] = true
+		}
+	}
+}
+
+// tokenize splits s into lowercase words, identifiers like set_cursor are
+// additionally split on underscores when parts is set.
+func tokenize(s string, parts bool) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	var words []string
+	for _, field := range fields {
+		field = strings.Trim(field, "_")
+		if field == "" {
+			continue
+		}
+
+		words = append(words, field)
+
+		if parts && strings.Contains(field, "_") {
+			for _, part := range strings.Split(field, "_") {
+				if part != "" {
+					words = append(words, part)
+				}
+			}
+		}
+	}
+
+	return words
+}
+
+// lookup returns the score of every document containing a word starting
+// with prefix, an exact word match in a name scores highest.
+func (idx *Index) lookup(prefix string) map[int]int {
+	scores := make(map[int]int)
+
+	i := sort.SearchStrings(idx.words, prefix)
+	for ; i < len(idx.words) && strings.HasPrefix(idx.words[i], prefix); i++ {
+		word := idx.words[i]
+
+		for _, p := range idx.postings[word] {
+			score := 1
+			if p.inName {
+				score = 4
+				if word == prefix {
+					score = 8
+				}
+			}
+
+			scores[p.document] = max(scores[p.document], score)
+		}
+	}
+
+	return scores
+}
+
+// Search returns the documents matching every word of query, treating each
+// word as a prefix, best matches first.
+func (idx *Index) Search(query string) []Result {
+	words := tokenize(query, false)
+	if len(words) == 0 {
+		return nil
+	}
+
+	var scores map[int]int
+	for _, word := range words {
+		wordScores := idx.lookup(word)
+
+		if scores == nil {
+			scores = wordScores
+			continue
+		}
+
+		for id, score := range scores {
+			if wordScore, ok := wordScores[id]; ok {
+				scores[id] = score + wordScore
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		doc := idx.documents[id]
+		results = append(results, Result{
+			Document: doc,
+			Context:  context(doc.Text, words),
+			score:    score,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+
+		return a.Path() < b.Path()
+	})
+
+	return results
+}
+
+func context(text string, words []string) string {
+	lines := strings.Split(text, "\n")
+
+	for _, line := range lines {
+		lower := strings.ToLower(line)
+		for _, word := range words {
+			if strings.Contains(lower, word) {
+				return strings.TrimSpace(line)
+			}
+		}
+	}
+
+	return strings.TrimSpace(lines[0])
+}
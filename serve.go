@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+	"wlpv/cli"
+	"wlpv/serve"
+)
+
+func runServe(opts cli.ServeOptions) int {
+	protocols, _, err := loadProtocols(opts.Offline, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	server, err := serve.New(protocols, opts.Additions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go server.Watch(time.Second, done)
+
+	fmt.Printf("serving on http://%s\n", opts.Addr)
+
+	if err := http.ListenAndServe(opts.Addr, server.Handler()); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,328 @@
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"wlpv/export"
+	"wlpv/schema"
+	"wlpv/search"
+	"wlpv/xmlparser"
+)
+
+const additionsNamespace = "User"
+
+const maxSearchResults = 100
+
+type addition struct {
+	modTime  time.Time
+	protocol xmlparser.Protocol
+}
+
+// state is everything served for one version of the loaded protocols.
+type state struct {
+	protocols map[string][]xmlparser.Protocol
+	site      map[string][]byte
+	search    *search.Index
+}
+
+// Server serves the HTML documentation and a JSON API for the loaded
+// protocols. Protocols read from files are parsed again when the files
+// change on disk.
+type Server struct {
+	sources map[string][]xmlparser.Protocol // protocols that never change
+	files   []string
+
+	mu        sync.RWMutex
+	additions map[string]addition // by file
+	state     state
+}
+
+func New(sources map[string][]xmlparser.Protocol, files []string) (*Server, error) {
+	s := &Server{
+		sources:   sources,
+		files:     files,
+		additions: make(map[string]addition),
+	}
+
+	for _, file := range files {
+		a, err := readAddition(file)
+		if err != nil {
+			return nil, err
+		}
+
+		s.additions[file] = a
+	}
+
+	if err := s.rebuild(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func readAddition(file string) (addition, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return addition{}, err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return addition{}, err
+	}
+
+	protocol, err := xmlparser.ParseProtocolFile(file, data)
+	if err != nil {
+		return addition{}, err
+	}
+
+	return addition{modTime: info.ModTime(), protocol: protocol}, nil
+}
+
+// rebuild regenerates the served state, the caller must hold the write lock
+// or have exclusive access to s.
+func (s *Server) rebuild() error {
+	protocols := make(map[string][]xmlparser.Protocol, len(s.sources)+1)
+	for namespace, group := range s.sources {
+		protocols[namespace] = group
+	}
+
+	for _, file := range s.files {
+		if a, ok := s.additions[file]; ok {
+			protocols[additionsNamespace] = append(protocols[additionsNamespace], a.protocol)
+		}
+	}
+
+	site, err := export.HTMLSite(protocols)
+	if err != nil {
+		return err
+	}
+
+	s.state = state{
+		protocols: protocols,
+		site:      site,
+		search:    search.New(protocols),
+	}
+
+	return nil
+}
+
+// Watch parses the files of the protocols again when their modification
+// time changes, checking every interval until done is closed. A file that
+// fails to parse keeps its previous version, files that fail to build the
+// site with are read again at the next check.
+func (s *Server) Watch(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		changed := make(map[string]addition)
+		var reloaded []string
+
+		s.mu.RLock()
+		for _, file := range s.files {
+			info, err := os.Stat(file)
+			if err != nil || info.ModTime().Equal(s.additions[file].modTime) {
+				continue
+			}
+
+			a, err := readAddition(file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: not reloading %v\n", err)
+				a = s.additions[file]
+				a.modTime = info.ModTime()
+			} else {
+				reloaded = append(reloaded, file)
+			}
+
+			changed[file] = a
+		}
+		s.mu.RUnlock()
+
+		if len(changed) == 0 {
+			continue
+		}
+
+		s.mu.Lock()
+		previous := make(map[string]addition, len(changed))
+		for file, a := range changed {
+			previous[file] = s.additions[file]
+			s.additions[file] = a
+		}
+
+		if err := s.rebuild(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+
+			for file, a := range previous {
+				s.additions[file] = a
+			}
+		} else {
+			for _, file := range reloaded {
+				fmt.Fprintf(os.Stderr, "reloaded %s\n", file)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *Server) current() state {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.state
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/protocols", s.handleProtocols)
+	mux.HandleFunc("GET /api/protocols/{name}", s.handleProtocol)
+	mux.HandleFunc("GET /api/search", s.handleSearch)
+	mux.HandleFunc("GET /", s.handleSite)
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Wlpv-Schema-Version", strconv.Itoa(schema.Version))
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, a ...any) {
+	writeJSON(w, status, map[string]string{"error": fmt.Sprintf(format, a...)})
+}
+
+func sortedNamespaces(protocols map[string][]xmlparser.Protocol) []string {
+	namespaces := make([]string, 0, len(protocols))
+	for namespace := range protocols {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces
+}
+
+// handleProtocols lists every protocol, or those of the namespace query
+// parameter.
+func (s *Server) handleProtocols(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	namespace := r.URL.Query().Get("namespace")
+
+	summaries := []schema.ProtocolSummary{}
+	for _, ns := range sortedNamespaces(st.protocols) {
+		if namespace != "" && ns != namespace {
+			continue
+		}
+
+		for _, protocol := range st.protocols[ns] {
+			summaries = append(summaries, schema.Summarize(ns, protocol))
+		}
+	}
+
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+// handleProtocol returns a protocol by name, the namespace query parameter
+// picks one if several namespaces have a protocol of that name.
+func (s *Server) handleProtocol(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	name := r.PathValue("name")
+	namespace := r.URL.Query().Get("namespace")
+
+	for _, ns := range sortedNamespaces(st.protocols) {
+		if namespace != "" && ns != namespace {
+			continue
+		}
+
+		for _, protocol := range st.protocols[ns] {
+			if protocol.Name == name {
+				writeJSON(w, http.StatusOK, schema.FromProtocol(ns, protocol))
+				return
+			}
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "no protocol named %q", name)
+}
+
+type searchResult struct {
+	Namespace string `json:"namespace"`
+	Protocol  string `json:"protocol"`
+	Kind      string `json:"kind"`
+	Path      string `json:"path"`
+	Context   string `json:"context,omitempty"`
+}
+
+// handleSearch runs a full-text query given by the q parameter, at most
+// limit results are returned.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	query := r.URL.Query().Get("q")
+
+	limit := maxSearchResults
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, "invalid limit %q", l)
+			return
+		}
+	}
+
+	results := []searchResult{}
+	for _, result := range st.search.Search(query) {
+		if len(results) == limit {
+			break
+		}
+
+		results = append(results, searchResult{
+			Namespace: result.Namespace,
+			Protocol:  result.Protocol,
+			Kind:      result.Kind.String(),
+			Path:      result.Path(),
+			Context:   result.Context,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) handleSite(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+
+	name := path.Clean(r.URL.Path)[1:]
+	if name == "" {
+		name = "index.html"
+	}
+
+	content, ok := st.site[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	w.Write(content)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"wlpv/cli"
+	"wlpv/trace"
+	"wlpv/tui"
+)
+
+func runTrace(opts cli.TraceOptions) int {
+	protocols, _, err := loadProtocols(opts.Offline, opts.Additions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	var input io.Reader = os.Stdin
+	if opts.File != "" && opts.File != "-" {
+		file, err := os.Open(opts.File)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+
+		input = file
+	}
+
+	lines, err := trace.Read(input, protocols)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if trace.Undecoded(lines) {
+		fmt.Fprintln(os.Stderr, "warning: no message could be decoded, the log may be in an unsupported format")
+	}
+
+	filter := trace.Filter{Object: opts.Object, Interface: opts.Interface}
+
+	var selected []trace.Line
+	for _, line := range lines {
+		if filter.Match(line) {
+			selected = append(selected, line)
+		}
+	}
+
+	if opts.TUI {
+		cfg, err := loadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+
+		if err := tui.RunTrace(selected, protocols, cfg.Namespaces()); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+
+		return 0
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	for _, line := range selected {
+		fmt.Fprintln(w, line.Text)
+
+		if annotation := line.Annotation(); annotation != "" {
+			fmt.Fprintf(w, "    %s\n", strings.ReplaceAll(annotation, "\n", "\n    "))
+		} else if line.IsMessage {
+			fmt.Fprintf(w, "    unknown message %s.%s\n", line.Interface, line.Name)
+		}
+
+		if warning := line.Warning(); warning != "" {
+			fmt.Fprintf(w, "    warning: %s\n", warning)
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,521 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"wlpv/resolver"
+	"wlpv/xmlparser"
+)
+
+// Direction tells whether the process that wrote the log sent or received
+// a message.
+type Direction uint8
+
+const (
+	Received Direction = iota
+	Sent
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Received:
+		return "received"
+	case Sent:
+		return "sent"
+	}
+
+	return ""
+}
+
+// Argument is an argument of a logged message. Name and Type come from the
+// protocol and are empty if the message is unknown.
+type Argument struct {
+	Name      string
+	Type      string
+	Raw       string // as written in the log
+	Object    uint32 // id of an object or new_id argument, 0 otherwise
+	Interface string // interface of an object or new_id argument
+	Enum      string // names of the enum entries of the value, if any
+}
+
+// String returns the decoded argument, like "format: 0 (argb8888)".
+func (a Argument) String() string {
+	var sb strings.Builder
+
+	if a.Name != "" {
+		sb.WriteString(a.Name)
+		sb.WriteString(": ")
+	}
+
+	switch {
+	case a.Type == "new_id" && a.Interface != "":
+		fmt.Fprintf(&sb, "new id %s#%d", a.Interface, a.Object)
+	case a.Enum != "":
+		fmt.Fprintf(&sb, "%s (%s)", a.Raw, a.Enum)
+	default:
+		sb.WriteString(a.Raw)
+	}
+
+	return sb.String()
+}
+
+// Line is a line of a WAYLAND_DEBUG log. Lines that are not messages only
+// have Number and Text set.
+type Line struct {
+	Number    int    // 1-based line number in the log
+	Text      string // as written in the log
+	IsMessage bool
+	Direction Direction
+	Object    uint32 // id of the object the message is sent to or from
+	Interface string // that the object was created with, if seen, else as logged
+	Name      string
+	Arguments []Argument
+
+	// Logged is the interface written in the log, set only when it differs
+	// from the one the object was created with.
+	Logged string
+
+	// Message is the definition of the message, nil if it is not found in
+	// the loaded protocols. Location is the interface it belongs to.
+	Message  *xmlparser.Message
+	Location resolver.Location
+}
+
+// Annotation returns the description of the message followed by its decoded
+// arguments, empty if the message is unknown.
+func (l Line) Annotation() string {
+	if l.Message == nil {
+		return ""
+	}
+
+	header := fmt.Sprintf("%s %s.%s (opcode %d)", l.Message.Kind, l.Interface, l.Name, l.Message.Opcode)
+	if summary := l.Message.Description.Summary; summary != "" {
+		header += ": " + summary
+	}
+
+	args := make([]string, len(l.Arguments))
+	for i, arg := range l.Arguments {
+		args[i] = arg.String()
+	}
+
+	if len(args) == 0 {
+		return header
+	}
+
+	return header + "\n" + strings.Join(args, ", ")
+}
+
+// Warning returns what the log contradicts, empty if nothing.
+func (l Line) Warning() string {
+	if l.Logged == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s#%d is logged as %s", l.Interface, l.Object, l.Logged)
+}
+
+// Element returns the element of the message in the rendered protocol.
+func (l Line) Element() xmlparser.Element {
+	kind := xmlparser.ElementRequest
+	if l.Message.Kind == xmlparser.MessageKindEvent {
+		kind = xmlparser.ElementEvent
+	}
+
+	return xmlparser.Element{Kind: kind, Interface: l.Interface, Member: l.Name}
+}
+
+// Filter selects the messages of an object or an interface, the zero value
+// selects every line.
+type Filter struct {
+	Object    uint32
+	Interface string
+}
+
+// Match reports whether the line is selected. A message matches an object
+// when it is sent to or from the object or has it as argument.
+func (f Filter) Match(l Line) bool {
+	if f.Object == 0 && f.Interface == "" {
+		return true
+	}
+
+	if !l.IsMessage {
+		return false
+	}
+
+	if f.Interface != "" && l.Interface != f.Interface {
+		return false
+	}
+
+	if f.Object != 0 && l.Object != f.Object {
+		for _, arg := range l.Arguments {
+			if arg.Object == f.Object {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// messagePattern matches a message as printed by libwayland, older versions
+// of which separate interfaces and ids with @ instead of #:
+//
+//	[1234567.890] {queue}  -> wl_surface#3.attach(wl_buffer#5, 0, 0)
+var messagePattern = regexp.MustCompile(
+	`^\[\s*[0-9.]+\]\s*(?:\{[^}]*\}\s*)?(->\s*)?(?:discarded\s+)?([A-Za-z_]\w*)[#@](\d+)\.([A-Za-z_]\w*)\((.*)\)\s*$`,
+)
+
+// timestampPattern matches the start of every line printed by libwayland.
+var timestampPattern = regexp.MustCompile(`^\[\s*[0-9.]+\]`)
+
+var (
+	objectPattern = regexp.MustCompile(`^([A-Za-z_]\w*)[#@](\d+)$`)
+	newIDPattern  = regexp.MustCompile(`^new id (\S+)[#@](\d+)$`)
+)
+
+// Decoder decodes the lines of a log in order, tracking the interfaces of
+// the objects created along the way.
+type Decoder struct {
+	index      *resolver.Index
+	interfaces map[resolver.Location]xmlparser.Interface
+	objects    map[uint32]string // interface by object id
+	client     bool              // whether the log was written by a client
+	sideKnown  bool
+	lines      int
+}
+
+func NewDecoder(protocols map[string][]xmlparser.Protocol) *Decoder {
+	d := &Decoder{
+		index:      resolver.New(protocols),
+		interfaces: make(map[resolver.Location]xmlparser.Interface),
+		objects:    map[uint32]string{1: "wl_display"},
+		client:     true,
+	}
+
+	for namespace, group := range protocols {
+		for _, protocol := range group {
+			for _, iface := range protocol.Interfaces {
+				d.interfaces[resolver.Location{
+					Namespace: namespace,
+					Protocol:  protocol.Name,
+					Interface: iface.Name,
+				}] = iface
+			}
+		}
+	}
+
+	return d
+}
+
+// Decode decodes the next line of the log.
+func (d *Decoder) Decode(text string) Line {
+	d.lines++
+	line := Line{Number: d.lines, Text: text}
+
+	groups := messagePattern.FindStringSubmatch(text)
+	if groups == nil {
+		return line
+	}
+
+	object, err := strconv.ParseUint(groups[3], 10, 32)
+	if err != nil {
+		return line
+	}
+
+	line.IsMessage = true
+	line.Object = uint32(object)
+	line.Interface = groups[2]
+	line.Name = groups[4]
+	if tracked, ok := d.objects[line.Object]; ok && tracked != line.Interface {
+		line.Logged = line.Interface
+		line.Interface = tracked
+	}
+	if groups[1] != "" {
+		line.Direction = Sent
+	}
+
+	raw := splitArguments(groups[5])
+
+	location, ok := d.index.Interface(line.Interface, resolver.Location{})
+	if ok {
+		iface := d.interfaces[location]
+		line.Location = location
+		line.Message = d.findMessage(iface, line.Name, line.Direction)
+	}
+
+	if line.Message != nil {
+		line.Arguments = d.decodeArguments(line, raw)
+	} else {
+		for _, r := range raw {
+			line.Arguments = append(line.Arguments, parseArgument(Argument{Raw: r}))
+		}
+	}
+
+	d.track(line)
+
+	return line
+}
+
+// findMessage looks up a message by name, using the direction to tell a
+// request from an event of the same name.
+func (d *Decoder) findMessage(iface xmlparser.Interface, name string, direction Direction) *xmlparser.Message {
+	var request, event *xmlparser.Message
+
+	for i := range iface.Requests {
+		if iface.Requests[i].Name == name {
+			request = &iface.Requests[i]
+		}
+	}
+
+	for i := range iface.Events {
+		if iface.Events[i].Name == name {
+			event = &iface.Events[i]
+		}
+	}
+
+	if request != nil && event != nil {
+		if (direction == Sent) == d.client {
+			return request
+		}
+
+		return event
+	}
+
+	if !d.sideKnown && (request != nil || event != nil) {
+		// a client sends requests and receives events
+		d.client = (request != nil) == (direction == Sent)
+		d.sideKnown = true
+	}
+
+	if request != nil {
+		return request
+	}
+
+	return event
+}
+
+// wireArguments returns the arguments of the message as they are sent,
+// where a new_id without interface is preceded by the interface name and
+// version of the new object.
+func wireArguments(m xmlparser.Message) []xmlparser.Argument {
+	var args []xmlparser.Argument
+
+	for _, arg := range m.Arguments {
+		if arg.Type == "new_id" && arg.Interface == "" {
+			args = append(args,
+				xmlparser.Argument{Name: "interface", Type: "string"},
+				xmlparser.Argument{Name: "version", Type: "uint"},
+			)
+		}
+
+		args = append(args, arg)
+	}
+
+	return args
+}
+
+func (d *Decoder) decodeArguments(line Line, raw []string) []Argument {
+	definitions := wireArguments(*line.Message)
+
+	var args []Argument
+	for i, r := range raw {
+		arg := Argument{Raw: r}
+
+		if i < len(definitions) {
+			definition := definitions[i]
+			arg.Name = definition.Name
+			arg.Type = definition.Type
+
+			if definition.Enum != "" {
+				arg.Enum = d.decodeEnum(definition.Enum, line.Location, r)
+			}
+		}
+
+		arg = parseArgument(arg)
+
+		if arg.Type == "new_id" && (arg.Interface == "" || arg.Interface == "[unknown]") && i > 1 {
+			arg.Interface = strings.Trim(raw[i-2], `"`)
+		} else if arg.Interface == "[unknown]" {
+			if iface, ok := d.objects[arg.Object]; ok {
+				arg.Interface = iface
+			}
+		}
+
+		args = append(args, arg)
+	}
+
+	return args
+}
+
+// parseArgument fills in the object id and interface of an argument from
+// its raw form.
+func parseArgument(arg Argument) Argument {
+	if groups := newIDPattern.FindStringSubmatch(arg.Raw); groups != nil {
+		arg.Type = "new_id"
+		arg.Interface = groups[1]
+		if id, err := strconv.ParseUint(groups[2], 10, 32); err == nil {
+			arg.Object = uint32(id)
+		}
+	} else if groups := objectPattern.FindStringSubmatch(arg.Raw); groups != nil {
+		arg.Interface = groups[1]
+		if id, err := strconv.ParseUint(groups[2], 10, 32); err == nil {
+			arg.Object = uint32(id)
+		}
+	}
+
+	return arg
+}
+
+// decodeEnum returns the names of the entries of the enum referenced from
+// the interface at location that make up the value, joined by "|" for a
+// bitfield.
+func (d *Decoder) decodeEnum(ref string, from resolver.Location, raw string) string {
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return ""
+	}
+	number := uint32(value)
+
+	location, ok := d.index.Enum(ref, from)
+	if !ok {
+		return ""
+	}
+
+	iface := d.interfaces[resolver.Location{
+		Namespace: location.Namespace,
+		Protocol:  location.Protocol,
+		Interface: location.Interface,
+	}]
+
+	for _, enum := range iface.Enums {
+		if enum.Name != location.Enum {
+			continue
+		}
+
+		if enum.Bitfield != "true" {
+			for _, entry := range enum.Entries {
+				if entry.NumberValid && entry.Number == number {
+					return entry.Name
+				}
+			}
+
+			return ""
+		}
+
+		var names []string
+		rest := number
+
+		for _, entry := range enum.Entries {
+			if !entry.NumberValid {
+				continue
+			}
+
+			if entry.Number == 0 && number == 0 {
+				return entry.Name
+			}
+
+			if entry.Number != 0 && number&entry.Number == entry.Number {
+				names = append(names, entry.Name)
+				rest &^= entry.Number
+			}
+		}
+
+		if rest != 0 {
+			names = append(names, fmt.Sprintf("0x%x", rest))
+		}
+
+		return strings.Join(names, "|")
+	}
+
+	return ""
+}
+
+// track records the objects created by the message and forgets those
+// deleted by wl_display.delete_id. An object first seen in a message, created
+// before the log started, is recorded with the interface it is logged as.
+func (d *Decoder) track(line Line) {
+	d.objects[line.Object] = line.Interface
+
+	for _, arg := range line.Arguments {
+		if arg.Type == "new_id" && arg.Object != 0 && arg.Interface != "" && arg.Interface != "[unknown]" {
+			d.objects[arg.Object] = arg.Interface
+		}
+	}
+
+	if line.Interface == "wl_display" && line.Name == "delete_id" && len(line.Arguments) == 1 {
+		if id, err := strconv.ParseUint(line.Arguments[0].Raw, 10, 32); err == nil {
+			delete(d.objects, uint32(id))
+		}
+	}
+}
+
+// splitArguments splits the arguments of a message at commas outside of
+// string arguments.
+func splitArguments(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+
+	var (
+		args     []string
+		start    int
+		inString bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inString = !inString
+		case ',':
+			if !inString {
+				args = append(args, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	return append(args, strings.TrimSpace(s[start:]))
+}
+
+// Undecoded reports whether lines look like a WAYLAND_DEBUG log, some of
+// them starting with a timestamp, but none was decoded as a message.
+func Undecoded(lines []Line) bool {
+	timestamped := false
+
+	for _, line := range lines {
+		if line.IsMessage {
+			return false
+		}
+
+		if timestampPattern.MatchString(line.Text) {
+			timestamped = true
+		}
+	}
+
+	return timestamped
+}
+
+// Read decodes every line of a log.
+func Read(r io.Reader, protocols map[string][]xmlparser.Protocol) ([]Line, error) {
+	decoder := NewDecoder(protocols)
+
+	var lines []Line
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, decoder.Decode(scanner.Text()))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
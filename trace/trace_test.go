@@ -0,0 +1,86 @@
+package trace
+
+import (
+	"strings"
+	"testing"
+	"wlpv/xmlparser"
+)
+
+const testProtocol = `<protocol name="wayland">
+  <interface name="wl_display" version="1">
+    <request name="get_registry"><arg name="registry" type="new_id" interface="wl_registry"/></request>
+    <event name="delete_id"><arg name="id" type="uint"/></event>
+  </interface>
+  <interface name="wl_registry" version="1">
+    <request name="bind"><arg name="name" type="uint"/><arg name="id" type="new_id"/></request>
+    <event name="global"><arg name="name" type="uint"/><arg name="interface" type="string"/><arg name="version" type="uint"/></event>
+  </interface>
+  <interface name="wl_compositor" version="6">
+    <request name="create_surface"><arg name="id" type="new_id" interface="wl_surface"/></request>
+  </interface>
+  <interface name="wl_surface" version="6">
+    <request name="destroy" type="destructor"/>
+  </interface>
+  <interface name="wl_seat" version="9">
+    <request name="release" type="destructor"/>
+  </interface>
+</protocol>`
+
+func TestDecodeTrackedInterface(t *testing.T) {
+	protocol, err := xmlparser.ParseProtocol([]byte(testProtocol))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := `[1.000]  -> wl_display#1.get_registry(new id wl_registry#2)
+[1.001] wl_registry#2.global(1, "wl_compositor", 6)
+[1.002]  -> wl_registry#2.bind(1, "wl_compositor", 6, new id [unknown]#3)
+[1.003]  -> wl_compositor#3.create_surface(new id wl_surface#4)
+[1.004]  -> wl_seat#4.destroy()
+[1.005] wl_display#1.delete_id(4)
+[1.006]  -> wl_seat#4.release()
+`
+
+	lines, err := Read(strings.NewReader(log), map[string][]xmlparser.Protocol{"core": {protocol}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line      int
+		iface     string
+		logged    string
+		arguments string
+	}{
+		{2, "wl_registry", "", `name: 1, interface: "wl_compositor", version: 6, id: new id wl_compositor#3`},
+		{3, "wl_compositor", "", "id: new id wl_surface#4"},
+		// the id is that of a surface until it is deleted
+		{4, "wl_surface", "wl_seat", ""},
+		{6, "wl_seat", "", ""},
+	}
+
+	for _, test := range tests {
+		line := lines[test.line]
+
+		if line.Interface != test.iface || line.Logged != test.logged {
+			t.Errorf("line %d: got interface %q logged as %q, want %q logged as %q", line.Number, line.Interface, line.Logged, test.iface, test.logged)
+		}
+
+		if line.Message == nil {
+			t.Errorf("line %d: message %s not found", line.Number, line.Name)
+		}
+
+		args := make([]string, len(line.Arguments))
+		for i, arg := range line.Arguments {
+			args[i] = arg.String()
+		}
+
+		if got := strings.Join(args, ", "); got != test.arguments {
+			t.Errorf("line %d: got arguments %q, want %q", line.Number, got, test.arguments)
+		}
+	}
+
+	if warning := lines[4].Warning(); warning != "wl_surface#4 is logged as wl_seat" {
+		t.Errorf("got warning %q", warning)
+	}
+}
@@ -0,0 +1,137 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"wlpv/diff"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	diffHeaderStyle = lipgloss.NewStyle().Bold(true)
+	diffColumnStyle = lipgloss.NewStyle().Padding(0, 1)
+	diffRightStyle  = diffColumnStyle.Border(lipgloss.NormalBorder(), false, false, false, true)
+)
+
+type diffItem struct {
+	change diff.Change
+}
+
+func (i diffItem) Title() string       { return i.change.String() }
+func (i diffItem) Description() string { return i.change.Protocol }
+func (i diffItem) FilterValue() string { return i.change.String() }
+
+func diffShortHelpCallback() []key.Binding {
+	return []key.Binding{
+		key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "compare"),
+		),
+	}
+}
+
+func newDiffList(changes []diff.Change) list.Model {
+	items := make([]list.Item, len(changes))
+	for i, change := range changes {
+		items[i] = diffItem{change: change}
+	}
+
+	delegate := list.NewDefaultDelegate()
+	delegate.ShortHelpFunc = diffShortHelpCallback
+
+	diffList := list.New(items, delegate, 0, 0)
+	diffList.Title = "diff"
+	diffList.SetStatusBarItemName("change", "changes")
+	return diffList
+}
+
+// sideBySide renders the old and the new version of a change in two
+// columns that fit in width.
+func sideBySide(change diff.Change, width int) string {
+	columnWidth := max(20, width/2)
+
+	column := func(style lipgloss.Style, header string, text string) string {
+		if text == "" {
+			text = "(none)"
+		}
+
+		return style.Width(columnWidth).Render(diffHeaderStyle.Render(header) + "\n\n" + text)
+	}
+
+	return fmt.Sprintf("%s\n%s\n\n%s",
+		diffHeaderStyle.Render(change.String()),
+		change.Protocol,
+		lipgloss.JoinHorizontal(lipgloss.Top,
+			column(diffColumnStyle, "old", change.Old),
+			column(diffRightStyle, "new", change.New),
+		),
+	)
+}
+
+func (m *model) refreshDiffDetail() {
+	selected, ok := m.diffList.SelectedItem().(diffItem)
+	if !ok {
+		return
+	}
+
+	m.viewport.SetContent(sideBySide(selected.change, m.viewport.Width))
+}
+
+func (m model) updateDiffView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.current == diffDetailView {
+		switch msg.String() {
+		case "esc", "q", "h":
+			m.current = diffView
+			m.pending = diffView
+			return m, nil
+		}
+
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+
+		return m, cmd
+	}
+
+	if (msg.String() == "enter" || msg.String() == "l") && m.diffList.FilterState() != list.Filtering {
+		if _, ok := m.diffList.SelectedItem().(diffItem); ok {
+			m.refreshDiffDetail()
+			m.viewport.GotoTop()
+			m.current = diffDetailView
+			m.pending = diffDetailView
+		}
+
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.diffList, cmd = m.diffList.Update(msg)
+
+	return m, cmd
+}
+
+func (m model) diffDetailViewString() string {
+	info := infoStyle.Render(fmt.Sprintf("%3.f%%", m.viewport.ScrollPercent()*100))
+	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(info)))
+
+	return fmt.Sprintf("%s\n%s", m.viewport.View(), lipgloss.JoinHorizontal(lipgloss.Center, line, info))
+}
+
+// RunDiff browses the changes between two versions of protocols, a change
+// opens with its old and new version side by side.
+func RunDiff(changes []diff.Change) error {
+	m := newModel("", nil, nil, nil, nil, nil)
+	m.diffList = newDiffList(changes)
+	m.current = diffView
+	m.pending = diffView
+
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	if _, err := p.Run(); err != nil {
+		return err
+	}
+
+	return nil
+}
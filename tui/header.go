@@ -0,0 +1,134 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	headerTitleStyle = lipgloss.NewStyle().Bold(true).Padding(0, 0, 0, 2)
+	headerCountStyle = lipgloss.NewStyle().Faint(true).Padding(0, 0, 0, 2)
+	bannerStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Padding(0, 0, 1, 2)
+)
+
+// Revision is what the protocols of a namespace were fetched from, Commit
+// is empty if unknown.
+type Revision struct {
+	Repository string
+	Commit     string
+	FetchedAt  time.Time
+}
+
+// header is the entry of the list that starts the protocols of a
+// namespace. It never matches a filter, so only protocols are listed while
+// filtering.
+type header struct {
+	namespace string
+	count     int
+	revision  Revision // zero if not fetched from a repository
+}
+
+func (h header) Title() string { return h.namespace }
+func (h header) Description() string {
+	description := fmt.Sprintf("%d protocols", h.count)
+	if h.count == 1 {
+		description = "1 protocol"
+	}
+
+	if h.revision.Repository == "" {
+		return description
+	}
+
+	description += " · " + h.revision.Repository
+	if commit := h.revision.Commit; commit != "" {
+		description += " @ " + commit[:min(len(commit), 7)]
+	}
+
+	return description + fmt.Sprintf(" (fetched %s)", ago(h.revision.FetchedAt))
+}
+func (h header) FilterValue() string { return "" }
+
+// ago returns how long ago t was, roughly.
+func ago(t time.Time) string {
+	d := time.Since(t)
+
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+
+	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+}
+
+// listDelegate renders headers apart from the protocols.
+type listDelegate struct {
+	list.DefaultDelegate
+}
+
+func (d listDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	h, ok := listItem.(header)
+	if !ok {
+		d.DefaultDelegate.Render(w, m, index, listItem)
+		return
+	}
+
+	title := headerTitleStyle.Render(fmt.Sprintf("── %s ", h.namespace))
+	if rule := m.Width() - lipgloss.Width(title); rule > 0 {
+		title += headerTitleStyle.UnsetPadding().Render(strings.Repeat("─", rule))
+	}
+
+	description := headerCountStyle.MaxWidth(m.Width()).Render(h.Description())
+
+	fmt.Fprintf(w, "%s\n%s", title, description)
+}
+
+// fetchBanner returns the lines listing the namespaces that could not be
+// fetched, entirely or in part, or an empty string if there are none.
+func fetchBanner(failed []string, incomplete []string) string {
+	var lines []string
+
+	if len(failed) > 0 {
+		lines = append(lines, "Could not fetch "+strings.Join(failed, ", "))
+	}
+
+	if len(incomplete) > 0 {
+		lines = append(lines, "Incomplete "+strings.Join(incomplete, ", "))
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return bannerStyle.Render(strings.Join(lines, "\n"))
+}
+
+func (m model) bannerHeight() int {
+	if m.banner == "" {
+		return 0
+	}
+
+	return lipgloss.Height(m.banner)
+}
+
+// skipHeader moves the selection off a header, to the protocol after it or,
+// if the selection moved up from previous, to the protocol before it.
+func (m *model) skipHeader(previous int) {
+	if _, ok := m.list.SelectedItem().(header); !ok {
+		return
+	}
+
+	if index := m.list.Index(); index < previous && index > 0 {
+		m.list.CursorUp()
+	} else {
+		m.list.CursorDown()
+	}
+}
@@ -0,0 +1,394 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"wlpv/xmlparser"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	outlineCursorStyle = lipgloss.NewStyle().Reverse(true)
+	outlineKindStyle   = lipgloss.NewStyle().Faint(true)
+	outlinePanelStyle  = lipgloss.NewStyle().Padding(0, 1)
+	outlineBorderStyle = lipgloss.NewStyle().
+				BorderStyle(lipgloss.NormalBorder()).
+				BorderRight(true)
+)
+
+type outlineNode struct {
+	element  xmlparser.Element
+	label    string
+	details  string
+	parent   *outlineNode
+	children []*outlineNode
+	expanded bool
+}
+
+func (n *outlineNode) add(child *outlineNode) {
+	child.parent = n
+	n.children = append(n.children, child)
+}
+
+type outlineRow struct {
+	node  *outlineNode
+	depth int
+}
+
+// outline is a collapsible tree of the elements of a protocol.
+type outline struct {
+	itemIndex int
+	root      *outlineNode
+	rows      []outlineRow // visible nodes, in order
+	cursor    int
+	offset    int // first row on screen
+}
+
+func cleanDescription(d xmlparser.Description) string {
+	var sb strings.Builder
+
+	if d.Summary != "" {
+		sb.WriteString(d.Summary)
+		sb.WriteString("\n\n")
+	}
+
+	sb.WriteString(d.Text())
+
+	return strings.TrimSpace(sb.String())
+}
+
+func newOutlineNode(element xmlparser.Element, label string, fields [][2]string, description string) *outlineNode {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%s %s\n\n", element.Kind, element.Path())
+	for _, field := range fields {
+		if field[1] != "" {
+			fmt.Fprintf(&sb, "%s: %s\n", field[0], field[1])
+		}
+	}
+
+	if description != "" {
+		sb.WriteByte('\n')
+		sb.WriteString(description)
+	}
+
+	return &outlineNode{element: element, label: label, details: sb.String()}
+}
+
+func newOutline(itemIndex int, protocol xmlparser.Protocol) outline {
+	root := newOutlineNode(
+		xmlparser.Element{Kind: xmlparser.ElementProtocol},
+		protocol.Name,
+		[][2]string{{"name", protocol.Name}},
+		cleanDescription(protocol.Description),
+	)
+	root.expanded = true
+
+	for _, iface := range protocol.Interfaces {
+		ifaceNode := newOutlineNode(
+			xmlparser.Element{Kind: xmlparser.ElementInterface, Interface: iface.Name},
+			iface.Name,
+			[][2]string{{"version", iface.Version}},
+			cleanDescription(iface.Description),
+		)
+		root.add(ifaceNode)
+
+		for _, message := range iface.Messages() {
+			kind, argKind := xmlparser.ElementRequest, xmlparser.ElementRequestArgument
+			if message.Kind == xmlparser.MessageKindEvent {
+				kind, argKind = xmlparser.ElementEvent, xmlparser.ElementEventArgument
+			}
+
+			var argNames []string
+			for _, arg := range message.Arguments {
+				argNames = append(argNames, arg.Name)
+			}
+
+			messageNode := newOutlineNode(
+				xmlparser.Element{Kind: kind, Interface: iface.Name, Member: message.Name},
+				fmt.Sprintf("%s(%s)", message.Name, strings.Join(argNames, ", ")),
+				[][2]string{
+					{"type", message.Type},
+					{"since", message.Since},
+					{"deprecated since", message.DeprecatedSince},
+				},
+				cleanDescription(message.Description),
+			)
+			ifaceNode.add(messageNode)
+
+			for _, arg := range message.Arguments {
+				messageNode.add(newOutlineNode(
+					xmlparser.Element{Kind: argKind, Interface: iface.Name, Member: message.Name, Child: arg.Name},
+					fmt.Sprintf("%s: %s", arg.Name, arg.TypeName()),
+					[][2]string{
+						{"type", arg.TypeName()},
+						{"summary", arg.Summary},
+						{"since", arg.Since},
+					},
+					cleanDescription(arg.Description),
+				))
+			}
+		}
+
+		for _, enum := range iface.Enums {
+			bitfield := ""
+			if enum.Bitfield == "true" {
+				bitfield = "yes"
+			}
+
+			enumNode := newOutlineNode(
+				xmlparser.Element{Kind: xmlparser.ElementEnum, Interface: iface.Name, Member: enum.Name},
+				enum.Name,
+				[][2]string{
+					{"bitfield", bitfield},
+					{"since", enum.Since},
+				},
+				cleanDescription(enum.Description),
+			)
+			ifaceNode.add(enumNode)
+
+			for _, entry := range enum.Entries {
+				enumNode.add(newOutlineNode(
+					xmlparser.Element{Kind: xmlparser.ElementEntry, Interface: iface.Name, Member: enum.Name, Child: entry.Name},
+					fmt.Sprintf("%s = %s", entry.Name, entry.FormatValue()),
+					[][2]string{
+						{"value", entry.FormatValue()},
+						{"summary", entry.Summary},
+						{"since", entry.Since},
+					},
+					cleanDescription(entry.Description),
+				))
+			}
+		}
+	}
+
+	o := outline{itemIndex: itemIndex, root: root}
+	o.refresh()
+
+	return o
+}
+
+func (o *outline) refresh() {
+	var selected *outlineNode
+	if o.cursor < len(o.rows) {
+		selected = o.rows[o.cursor].node
+	}
+
+	o.rows = o.rows[:0]
+
+	var walk func(node *outlineNode, depth int)
+	walk = func(node *outlineNode, depth int) {
+		o.rows = append(o.rows, outlineRow{node: node, depth: depth})
+
+		if node.expanded {
+			for _, child := range node.children {
+				walk(child, depth+1)
+			}
+		}
+	}
+	walk(o.root, 0)
+
+	o.cursor = 0
+	for i, row := range o.rows {
+		if row.node == selected {
+			o.cursor = i
+		}
+	}
+}
+
+func (o *outline) selected() *outlineNode {
+	return o.rows[o.cursor].node
+}
+
+func (o *outline) move(step int) {
+	o.cursor = min(max(o.cursor+step, 0), len(o.rows)-1)
+}
+
+func (o *outline) expand() {
+	node := o.selected()
+	if len(node.children) == 0 {
+		return
+	}
+
+	if node.expanded {
+		o.move(1)
+		return
+	}
+
+	node.expanded = true
+	o.refresh()
+}
+
+// collapse closes the selected node, or moves to its parent if it is not
+// open.
+func (o *outline) collapse() {
+	node := o.selected()
+
+	if node.expanded && len(node.children) > 0 {
+		node.expanded = false
+	} else if node.parent != nil {
+		node = node.parent
+		for i, row := range o.rows {
+			if row.node == node {
+				o.cursor = i
+			}
+		}
+	}
+
+	o.refresh()
+}
+
+// reveal expands the ancestors of the deepest node starting at or before
+// line and selects it.
+func (o *outline) reveal(layout xmlparser.Layout, line int) {
+	best := o.root
+	bestLine := -1
+
+	var walk func(node *outlineNode)
+	walk = func(node *outlineNode) {
+		if nodeLine, ok := layout[node.element]; ok && nodeLine <= line && nodeLine >= bestLine {
+			best = node
+			bestLine = nodeLine
+		}
+
+		for _, child := range node.children {
+			walk(child)
+		}
+	}
+	walk(o.root)
+
+	for node := best.parent; node != nil; node = node.parent {
+		node.expanded = true
+	}
+
+	o.refresh()
+	for i, row := range o.rows {
+		if row.node == best {
+			o.cursor = i
+		}
+	}
+}
+
+func (o *outline) view(width int, height int) string {
+	if o.cursor < o.offset {
+		o.offset = o.cursor
+	} else if o.cursor >= o.offset+height {
+		o.offset = o.cursor - height + 1
+	}
+
+	treeWidth := max(30, width*2/5)
+	detailsWidth := max(0, width-treeWidth-3)
+
+	var lines []string
+	for i := o.offset; i < len(o.rows) && i < o.offset+height; i++ {
+		row := o.rows[i]
+
+		marker := "  "
+		if len(row.node.children) > 0 {
+			marker = "▸ "
+			if row.node.expanded {
+				marker = "▾ "
+			}
+		}
+
+		kind := ""
+		if row.depth > 0 {
+			kind = outlineKindStyle.Render(row.node.element.Kind.String() + " ")
+		}
+
+		label := strings.Repeat("  ", row.depth) + marker
+		maxLabelWidth := treeWidth - lipgloss.Width(label) - lipgloss.Width(kind)
+		text := row.node.label
+		if maxLabelWidth > 1 && lipgloss.Width(text) > maxLabelWidth {
+			text = string([]rune(text)[:maxLabelWidth-1]) + "…"
+		}
+
+		if i == o.cursor {
+			text = outlineCursorStyle.Render(text)
+		}
+
+		lines = append(lines, label+kind+text)
+	}
+
+	tree := outlineBorderStyle.
+		Width(treeWidth).
+		Height(height).
+		MaxHeight(height).
+		Render(strings.Join(lines, "\n"))
+
+	details := outlinePanelStyle.
+		Width(detailsWidth).
+		Height(height).
+		MaxHeight(height).
+		Render(o.selected().details)
+
+	return lipgloss.JoinHorizontal(lipgloss.Top, tree, details)
+}
+
+func (m *model) enterOutlineView(itemIndex int, line int) {
+	if m.outline.root == nil || m.outline.itemIndex != itemIndex {
+		m.outline = newOutline(itemIndex, m.items[itemIndex].protocol)
+	}
+
+	if line >= 0 {
+		_, layout := m.items[itemIndex].protocol.RenderLayout()
+		m.outline.reveal(layout, line)
+	}
+
+	m.pending = outlineView
+}
+
+func (m model) updateOutlineView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		m.outline.move(-1)
+	case "down", "j":
+		m.outline.move(1)
+	case "pgup", "ctrl+u":
+		m.outline.move(-m.viewport.Height / 2)
+	case "pgdown", "ctrl+d":
+		m.outline.move(m.viewport.Height / 2)
+	case "g", "home":
+		m.outline.move(-len(m.outline.rows))
+	case "G", "end":
+		m.outline.move(len(m.outline.rows))
+	case "right", "l":
+		m.outline.expand()
+	case "left", "h":
+		m.outline.collapse()
+	case " ":
+		node := m.outline.selected()
+		node.expanded = !node.expanded
+		m.outline.refresh()
+
+	case "enter":
+		m.openItem(m.outline.itemIndex, 0)
+		m.viewport.SetYOffset(m.layout.Line(m.outline.selected().element))
+		m.pagerParent = outlineView
+		m.pending = pagerView
+
+	case "esc", "q":
+		m.pending = listView
+	}
+
+	m.current = m.pending
+
+	return m, nil
+}
+
+func (m model) outlineViewString() string {
+	footer := m.outlineFooterView()
+	height := m.viewport.Height + lipgloss.Height(m.footerView()) - lipgloss.Height(footer)
+
+	return fmt.Sprintf("%s\n%s", m.outline.view(m.viewport.Width, height), footer)
+}
+
+func (m model) outlineFooterView() string {
+	title := fmt.Sprintf("%s ", m.items[m.outline.itemIndex].protocol.Name)
+	info := infoStyle.Render("enter: open  space: toggle  esc: back")
+	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(info)-lipgloss.Width(title)))
+
+	return lipgloss.JoinHorizontal(lipgloss.Center, title, line, info)
+}
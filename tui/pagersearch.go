@@ -0,0 +1,308 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	matchStyle        = lipgloss.NewStyle().Background(lipgloss.Color("3")).Foreground(lipgloss.Color("0"))
+	currentMatchStyle = lipgloss.NewStyle().Background(lipgloss.Color("208")).Foreground(lipgloss.Color("0"))
+)
+
+// pagerSearch is a query entered with / or ? in the pager.
+type pagerSearch struct {
+	pattern  string
+	regex    bool
+	backward bool
+}
+
+func (s pagerSearch) compile() (*regexp.Regexp, error) {
+	pattern := s.pattern
+	if !s.regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+
+	// smart case, like less -i
+	if strings.ToLower(s.pattern) == s.pattern {
+		pattern = "(?i)" + pattern
+	}
+
+	return regexp.Compile(pattern)
+}
+
+type match struct {
+	line  int
+	start int
+	end   int
+}
+
+// highlight is a styled byte range of a line of the pager content.
+type highlight struct {
+	match
+	style lipgloss.Style
+}
+
+func newPagerInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "/"
+	return input
+}
+
+func (m *model) startPagerPrompt(backward bool) tea.Cmd {
+	m.pagerPrompting = true
+	m.pagerPromptSearch = pagerSearch{backward: backward}
+	m.pagerPromptYOffset = m.viewport.YOffset
+	m.pagerPromptPrevious = m.items[m.selectedItemIndex].pagerSearch
+	m.pagerInput.SetValue("")
+	m.updatePagerPromptString()
+
+	return m.pagerInput.Focus()
+}
+
+func (m *model) updatePagerPromptString() {
+	prompt := "/"
+	if m.pagerPromptSearch.backward {
+		prompt = "?"
+	}
+
+	if m.pagerPromptSearch.regex {
+		prompt += "(regex) "
+	}
+
+	m.pagerInput.Prompt = prompt
+}
+
+func (m model) updatePagerPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "esc":
+		m.pagerPrompting = false
+		m.pagerInput.Blur()
+
+		m.items[m.selectedItemIndex].pagerSearch = m.pagerPromptPrevious
+		m.findMatches()
+		m.refreshContent()
+		m.viewport.SetYOffset(m.pagerPromptYOffset)
+
+	case "ctrl+r":
+		m.pagerPromptSearch.regex = !m.pagerPromptSearch.regex
+		m.updatePagerPromptString()
+		m.previewPagerSearch()
+
+	case "enter":
+		m.pagerPrompting = false
+		m.pagerInput.Blur()
+
+		search := m.pagerPromptSearch
+		search.pattern = m.pagerInput.Value()
+
+		// an empty pattern repeats the last search in the new direction
+		if search.pattern == "" {
+			search.pattern = m.pagerPromptPrevious.pattern
+			search.regex = m.pagerPromptPrevious.regex
+		}
+
+		m.viewport.SetYOffset(m.pagerPromptYOffset)
+		if search.pattern != "" {
+			m.runPagerSearch(search)
+		} else {
+			m.items[m.selectedItemIndex].pagerSearch = m.pagerPromptPrevious
+		}
+
+	default:
+		query := m.pagerInput.Value()
+		m.pagerInput, cmd = m.pagerInput.Update(msg)
+
+		if m.pagerInput.Value() != query {
+			m.previewPagerSearch()
+		}
+	}
+
+	return m, cmd
+}
+
+// previewPagerSearch searches for the pattern being typed at the prompt,
+// starting from where the prompt was opened.
+func (m *model) previewPagerSearch() {
+	search := m.pagerPromptSearch
+	search.pattern = m.pagerInput.Value()
+
+	m.viewport.SetYOffset(m.pagerPromptYOffset)
+	m.runPagerSearch(search)
+}
+
+func (m *model) runPagerSearch(search pagerSearch) {
+	m.items[m.selectedItemIndex].pagerSearch = search
+
+	m.findMatches()
+	m.refreshContent()
+	m.nextMatch(false)
+}
+
+// findMatches searches the pager content for the query of the current item.
+func (m *model) findMatches() {
+	m.matches = nil
+	m.currentMatch = -1
+	m.pagerSearchError = nil
+
+	search := m.items[m.selectedItemIndex].pagerSearch
+	if search.pattern == "" {
+		return
+	}
+
+	re, err := search.compile()
+	if err != nil {
+		m.pagerSearchError = err
+		return
+	}
+
+	for lineIndex, line := range strings.Split(m.content, "\n") {
+		for _, loc := range re.FindAllStringIndex(line, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+
+			m.matches = append(m.matches, match{line: lineIndex, start: loc[0], end: loc[1]})
+		}
+	}
+}
+
+// nextMatch moves to the next match in the direction of the search, or the
+// opposite one if reverse is set, starting from the top of the screen when
+// no match is current.
+func (m *model) nextMatch(reverse bool) {
+	if len(m.matches) == 0 {
+		return
+	}
+
+	backward := m.items[m.selectedItemIndex].pagerSearch.backward != reverse
+
+	if m.currentMatch == -1 {
+		top := m.viewport.YOffset
+
+		if backward {
+			m.currentMatch = len(m.matches) - 1
+			for i := len(m.matches) - 1; i >= 0; i-- {
+				if m.matches[i].line < top {
+					m.currentMatch = i
+					break
+				}
+			}
+		} else {
+			m.currentMatch = 0
+			for i, match := range m.matches {
+				if match.line >= top {
+					m.currentMatch = i
+					break
+				}
+			}
+		}
+	} else if backward {
+		m.currentMatch = (m.currentMatch - 1 + len(m.matches)) % len(m.matches)
+	} else {
+		m.currentMatch = (m.currentMatch + 1) % len(m.matches)
+	}
+
+	m.refreshContent()
+
+	line := m.matches[m.currentMatch].line
+	if line < m.viewport.YOffset || line >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(line)
+	}
+}
+
+func (m model) matchInfo() string {
+	if m.selectedItemIndex == -1 {
+		return ""
+	}
+
+	search := m.items[m.selectedItemIndex].pagerSearch
+
+	switch {
+	case m.pagerSearchError != nil:
+		return "invalid pattern"
+	case search.pattern == "":
+		return ""
+	case len(m.matches) == 0:
+		return "pattern not found"
+	case m.currentMatch == -1:
+		return fmt.Sprintf("%d matches", len(m.matches))
+	}
+
+	return fmt.Sprintf("match %d/%d", m.currentMatch+1, len(m.matches))
+}
+
+// refreshContent sets the pager content with search matches and the
+// selected reference highlighted.
+func (m *model) refreshContent() {
+	var highlights []highlight
+
+	for i, match := range m.matches {
+		style := matchStyle
+		if i == m.currentMatch {
+			style = currentMatchStyle
+		}
+
+		highlights = append(highlights, highlight{match: match, style: style})
+	}
+
+	if m.selectedRef != -1 {
+		ref := m.references[m.selectedRef]
+		highlights = append(highlights, highlight{
+			match: match{line: ref.line, start: ref.start, end: ref.end},
+			style: referenceStyle,
+		})
+	}
+
+	m.viewport.SetContent(applyHighlights(m.content, highlights))
+}
+
+// applyHighlights styles the given ranges of content, a range overlapping
+// an earlier one on the same line is dropped.
+func applyHighlights(content string, highlights []highlight) string {
+	if len(highlights) == 0 {
+		return content
+	}
+
+	sort.SliceStable(highlights, func(i, j int) bool {
+		if highlights[i].line != highlights[j].line {
+			return highlights[i].line < highlights[j].line
+		}
+
+		return highlights[i].start < highlights[j].start
+	})
+
+	lines := strings.Split(content, "\n")
+
+	for i := 0; i < len(highlights); {
+		lineIndex := highlights[i].line
+		line := lines[lineIndex]
+
+		var sb strings.Builder
+		last := 0
+
+		for ; i < len(highlights) && highlights[i].line == lineIndex; i++ {
+			h := highlights[i]
+			if h.start < last {
+				continue
+			}
+
+			sb.WriteString(line[last:h.start])
+			sb.WriteString(h.style.Render(line[h.start:h.end]))
+			last = h.end
+		}
+
+		sb.WriteString(line[last:])
+		lines[lineIndex] = sb.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"regexp"
+	"strings"
+	"wlpv/resolver"
+	"wlpv/xmlparser"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	referenceRegexp = regexp.MustCompile(`<([A-Za-z_][A-Za-z0-9_.]*)>`)
+	referenceStyle  = lipgloss.NewStyle().Reverse(true)
+)
+
+// reference is an interface or enum name shown in the pager, at a byte
+// offset within a line of the rendered protocol.
+type reference struct {
+	line   int
+	start  int
+	end    int
+	target resolver.Location
+}
+
+// pagerLocation is an entry of the back stack of followed references.
+type pagerLocation struct {
+	itemIndex   int
+	yOffset     int
+	selectedRef int
+}
+
+func findReferences(content string, index *resolver.Index, from resolver.Location) []reference {
+	var references []reference
+
+	for lineIndex, line := range strings.Split(content, "\n") {
+		if name, found := strings.CutPrefix(line, "interface: "); found {
+			from.Interface, _, _ = strings.Cut(name, " ")
+		}
+
+		for _, match := range referenceRegexp.FindAllStringSubmatchIndex(line, -1) {
+			name := line[match[2]:match[3]]
+
+			target, ok := index.Interface(name, from)
+			if !ok {
+				target, ok = index.Enum(name, from)
+			}
+			if !ok {
+				continue
+			}
+
+			references = append(references, reference{
+				line:   lineIndex,
+				start:  match[2],
+				end:    match[3],
+				target: target,
+			})
+		}
+	}
+
+	return references
+}
+
+// definitionElement returns the element declaring the given interface or enum.
+func definitionElement(location resolver.Location) xmlparser.Element {
+	if location.Kind() == resolver.KindEnum {
+		return xmlparser.Element{
+			Kind:      xmlparser.ElementEnum,
+			Interface: location.Interface,
+			Member:    location.Enum,
+		}
+	}
+
+	return xmlparser.Element{Kind: xmlparser.ElementInterface, Interface: location.Interface}
+}
@@ -0,0 +1,106 @@
+package tui
+
+import (
+	"fmt"
+	"wlpv/search"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const maxSearchResults = 500
+
+type searchItem struct {
+	result search.Result
+}
+
+func (i searchItem) Title() string {
+	return fmt.Sprintf("%s / %s / %s (%s)",
+		i.result.Namespace,
+		i.result.Protocol,
+		i.result.Path(),
+		i.result.Kind,
+	)
+}
+func (i searchItem) Description() string { return i.result.Context }
+func (i searchItem) FilterValue() string { return i.result.Path() }
+
+func newSearchInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "search: "
+	input.Placeholder = "interfaces, messages, enums, arguments and descriptions"
+	return input
+}
+
+func newSearchList() list.Model {
+	searchList := list.New(nil, list.NewDefaultDelegate(), 0, 0)
+	searchList.SetShowTitle(false)
+	searchList.SetShowHelp(false)
+	searchList.SetFilteringEnabled(false)
+	searchList.SetStatusBarItemName("result", "results")
+	return searchList
+}
+
+func (m *model) enterSearchView() {
+	m.previous = m.current
+	m.pending = searchView
+	m.searchInput.Focus()
+}
+
+func (m model) updateSearchView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "esc":
+		m.searchInput.Blur()
+		m.pending = m.previous
+		m.current = m.pending
+
+	case "enter":
+		selected, ok := m.searchList.SelectedItem().(searchItem)
+		if !ok {
+			break
+		}
+
+		for index, item := range m.items {
+			if item.namespace == selected.result.Namespace && item.protocol.Name == selected.result.Protocol {
+				m.searchInput.Blur()
+				m.openItem(index, 0)
+				m.viewport.SetYOffset(m.layout.Line(selected.result.Element))
+				m.pagerParent = listView
+				m.pending = pagerView
+				m.current = m.pending
+				break
+			}
+		}
+
+	case "up", "down", "ctrl+p", "ctrl+n", "pgup", "pgdown":
+		m.searchList, cmd = m.searchList.Update(msg)
+
+	default:
+		query := m.searchInput.Value()
+		m.searchInput, cmd = m.searchInput.Update(msg)
+
+		if m.searchInput.Value() != query {
+			results := m.searchIndex.Search(m.searchInput.Value())
+			if len(results) > maxSearchResults {
+				results = results[:maxSearchResults]
+			}
+
+			items := make([]list.Item, len(results))
+			for i, result := range results {
+				items[i] = searchItem{result: result}
+			}
+
+			m.searchList.ResetSelected()
+			cmd = tea.Batch(cmd, m.searchList.SetItems(items))
+		}
+	}
+
+	return m, cmd
+}
+
+func (m model) searchViewString() string {
+	return docStyle.Render(fmt.Sprintf("%s\n\n%s", m.searchInput.View(), m.searchList.View()))
+}
@@ -0,0 +1,98 @@
+package tui
+
+import (
+	"strings"
+	"wlpv/trace"
+	"wlpv/xmlparser"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type traceItem struct {
+	line trace.Line
+}
+
+func (i traceItem) Title() string { return i.line.Text }
+func (i traceItem) Description() string {
+	description := strings.ReplaceAll(i.line.Annotation(), "\n", " — ")
+	if i.line.Message == nil && i.line.IsMessage {
+		description = "unknown message"
+	}
+
+	if warning := i.line.Warning(); warning != "" {
+		description = strings.TrimPrefix(description+" — warning: "+warning, " — ")
+	}
+
+	return description
+}
+func (i traceItem) FilterValue() string { return i.line.Text }
+
+func traceShortHelpCallback() []key.Binding {
+	return []key.Binding{
+		key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "open message"),
+		),
+	}
+}
+
+func newTraceList(lines []trace.Line) list.Model {
+	items := make([]list.Item, len(lines))
+	for i, line := range lines {
+		items[i] = traceItem{line: line}
+	}
+
+	delegate := list.NewDefaultDelegate()
+	delegate.ShortHelpFunc = traceShortHelpCallback
+
+	traceList := list.New(items, delegate, 0, 0)
+	traceList.Title = "trace"
+	traceList.SetStatusBarItemName("line", "lines")
+	return traceList
+}
+
+func (m model) updateTraceView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.String() == "enter" && m.traceList.FilterState() != list.Filtering {
+		selected, ok := m.traceList.SelectedItem().(traceItem)
+		if !ok || selected.line.Message == nil {
+			return m, nil
+		}
+
+		location := selected.line.Location
+		for index, item := range m.items {
+			if item.namespace == location.Namespace && item.protocol.Name == location.Protocol {
+				m.openItem(index, 0)
+				m.viewport.SetYOffset(m.layout.Line(selected.line.Element()))
+				m.pagerParent = traceView
+				m.pending = pagerView
+				m.current = m.pending
+				break
+			}
+		}
+
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.traceList, cmd = m.traceList.Update(msg)
+
+	return m, cmd
+}
+
+// RunTrace browses the lines of a decoded WAYLAND_DEBUG log, the message of
+// a line opens in the pager.
+func RunTrace(lines []trace.Line, protocols map[string][]xmlparser.Protocol, namespaces []string) error {
+	m := newModel("", protocols, nil, nil, nil, namespaces)
+	m.traceList = newTraceList(lines)
+	m.current = traceView
+	m.pending = traceView
+
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	if _, err := p.Run(); err != nil {
+		return err
+	}
+
+	return nil
+}
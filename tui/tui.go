@@ -2,12 +2,16 @@ package tui
 
 import (
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
+	"wlpv/resolver"
+	"wlpv/search"
 	"wlpv/xmlparser"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -19,17 +23,26 @@ var (
 	infoStyle  = lipgloss.NewStyle().Padding(0, 1)
 )
 
+// additionsNamespace is the namespace of the protocols added with -a.
+const additionsNamespace = "User"
+
 type view uint8
 
 const (
 	listView view = iota
 	pagerView
+	searchView
+	outlineView
+	traceView
+	diffView
+	diffDetailView
 )
 
 type item struct {
 	protocol     xmlparser.Protocol
 	namespace    string
 	pagerYOffset int
+	pagerSearch  pagerSearch // last search in the pager
 }
 
 func newItem(protocol xmlparser.Protocol, namespace string) item {
@@ -44,13 +57,36 @@ func (i item) Description() string { return i.namespace }
 func (i item) FilterValue() string { return i.protocol.Name }
 
 type model struct {
-	list              list.Model
-	viewport          viewport.Model
-	ready             bool
-	pending           view
-	current           view
-	selectedItemIndex int
-	items             []item
+	list                list.Model
+	viewport            viewport.Model
+	ready               bool
+	pending             view
+	current             view
+	selectedItemIndex   int
+	items               []item
+	index               *resolver.Index
+	content             string           // rendered protocol shown in the pager
+	layout              xmlparser.Layout // lines of the elements in content
+	references          []reference      // references found in content
+	selectedRef         int              // index into references, -1 if none
+	history             []pagerLocation
+	searchIndex         *search.Index
+	searchInput         textinput.Model
+	searchList          list.Model
+	previous            view // view to return to when leaving the search view
+	pagerInput          textinput.Model
+	pagerPrompting      bool
+	pagerPromptSearch   pagerSearch // search being entered at the prompt
+	pagerPromptYOffset  int         // where the prompt was opened
+	pagerPromptPrevious pagerSearch // search to restore if the prompt is cancelled
+	pagerSearchError    error
+	matches             []match // matches of the pager search in content
+	currentMatch        int     // index into matches, -1 if none
+	outline             outline
+	pagerParent         view // view to return to when leaving the pager
+	traceList           list.Model
+	diffList            list.Model
+	banner              string // namespaces that could not be fetched, shown above the list
 }
 
 func (m model) Init() tea.Cmd {
@@ -65,10 +101,37 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.current == searchView && msg.String() != "ctrl+c" {
+			return m.updateSearchView(msg)
+		}
+
+		if m.current == pagerView && m.pagerPrompting && msg.String() != "ctrl+c" {
+			return m.updatePagerPrompt(msg)
+		}
+
+		if m.current == outlineView && msg.String() != "ctrl+c" {
+			return m.updateOutlineView(msg)
+		}
+
+		if m.current == traceView && msg.String() != "ctrl+c" {
+			return m.updateTraceView(msg)
+		}
+
+		if (m.current == diffView || m.current == diffDetailView) && msg.String() != "ctrl+c" {
+			return m.updateDiffView(msg)
+		}
+
 		switch msg.String() {
 		case "ctrl+c":
 			return m, tea.Quit
 
+		case "ctrl+f":
+			if m.current == pagerView || m.list.FilterState() != list.Filtering {
+				m.enterSearchView()
+				m.current = m.pending
+				return m, textinput.Blink
+			}
+
 		case "esc", "q", "h":
 			if m.current == pagerView {
 				m.exitPagerView()
@@ -76,21 +139,54 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case "enter", "l":
 			if m.current == listView {
-				{
-					selectedItem := m.list.SelectedItem().(item)
+				selectedItem, ok := m.list.SelectedItem().(item)
+				if !ok {
+					break
+				}
+
+				for index, item := range m.items {
+					if item.namespace == selectedItem.namespace && item.protocol.Name == selectedItem.protocol.Name {
+						m.openItem(index, item.pagerYOffset)
+						break
+					}
+				}
+
+				m.pagerParent = listView
+				m.pending = pagerView
+			} else if m.current == pagerView && msg.String() == "enter" {
+				m.followReference()
+			}
+
+		case "o":
+			if m.current == pagerView {
+				m.enterOutlineView(m.selectedItemIndex, m.viewport.YOffset)
+				m.current = m.pending
+				return m, nil
+			} else if m.current == listView && m.list.FilterState() != list.Filtering {
+				if selectedItem, ok := m.list.SelectedItem().(item); ok {
 					for index, item := range m.items {
-						if item.protocol.Name == selectedItem.protocol.Name {
-							m.viewport.SetYOffset(item.pagerYOffset)
-							m.selectedItemIndex = index
-							break
+						if item.namespace == selectedItem.namespace && item.protocol.Name == selectedItem.protocol.Name {
+							m.enterOutlineView(index, -1)
+							m.current = m.pending
+							return m, nil
 						}
 					}
 				}
+			}
 
-				m.pending = pagerView
+		case "tab":
+			if m.current == pagerView {
+				m.selectReference(1)
+			}
 
-				selectedItem := m.items[m.selectedItemIndex]
-				m.viewport.SetContent(selectedItem.protocol.Render())
+		case "shift+tab":
+			if m.current == pagerView {
+				m.selectReference(-1)
+			}
+
+		case "backspace", "ctrl+o":
+			if m.current == pagerView {
+				m.goBack()
 			}
 
 		case "g":
@@ -102,24 +198,42 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.current == pagerView {
 				m.viewport.GotoBottom()
 			}
+
+		case "/", "?":
+			if m.current == pagerView {
+				return m, m.startPagerPrompt(msg.String() == "?")
+			}
+
+		case "n", "N":
+			if m.current == pagerView {
+				m.nextMatch(msg.String() == "N")
+			}
 		}
 
 	case tea.WindowSizeMsg:
 		h, v := docStyle.GetFrameSize()
-		m.list.SetSize(msg.Width-h, msg.Height-v)
+		m.list.SetSize(msg.Width-h, msg.Height-v-m.bannerHeight())
+		m.searchList.SetSize(msg.Width-h, msg.Height-v-2)
+		m.searchInput.Width = msg.Width - h - len(m.searchInput.Prompt) - 1
+		m.traceList.SetSize(msg.Width-h, msg.Height-v)
+		m.diffList.SetSize(msg.Width-h, msg.Height-v)
 
 		footerHeight := lipgloss.Height(m.footerView())
 
 		if !m.ready {
 			m.viewport = viewport.New(msg.Width, msg.Height-footerHeight)
 			if m.selectedItemIndex != -1 {
-				m.viewport.SetContent(m.items[m.selectedItemIndex].protocol.Render())
+				m.openItem(m.selectedItemIndex, 0)
 			}
 			m.ready = true
 		} else {
 			m.viewport.Width = msg.Width
 			m.viewport.Height = msg.Height - footerHeight
 		}
+
+		if m.current == diffDetailView {
+			m.refreshDiffDetail()
+		}
 	}
 
 	switch m.current {
@@ -127,8 +241,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.viewport, cmd = m.viewport.Update(msg)
 		cmds = append(cmds, cmd)
 	case listView:
+		previous := m.list.Index()
 		m.list, cmd = m.list.Update(msg)
 		cmds = append(cmds, cmd)
+		m.skipHeader(previous)
 	}
 
 	m.current = m.pending
@@ -137,13 +253,116 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m *model) exitPagerView() {
-	m.pending = listView
+	m.pending = m.pagerParent
 	selectedItem := &m.items[m.selectedItemIndex]
 	selectedItem.pagerYOffset = m.viewport.YOffset
+	m.history = nil
+}
+
+func (m *model) openItem(index int, yOffset int) {
+	selectedItem := m.items[index]
+
+	m.selectedItemIndex = index
+	m.content, m.layout = selectedItem.protocol.RenderLayout()
+	m.references = findReferences(m.content, m.index, resolver.Location{
+		Namespace: selectedItem.namespace,
+		Protocol:  selectedItem.protocol.Name,
+	})
+	m.selectedRef = -1
+
+	m.findMatches()
+	m.refreshContent()
+	m.viewport.SetYOffset(yOffset)
+}
+
+// selectReference moves the reference selection by step, starting from the
+// first reference on screen if none is selected.
+func (m *model) selectReference(step int) {
+	if len(m.references) == 0 {
+		return
+	}
+
+	if m.selectedRef == -1 {
+		m.selectedRef = len(m.references) - 1
+		if step < 0 {
+			m.selectedRef = 0
+		}
+
+		for i, ref := range m.references {
+			if ref.line >= m.viewport.YOffset {
+				m.selectedRef = i - step
+				break
+			}
+		}
+	}
+
+	m.selectedRef = (m.selectedRef + step + len(m.references)) % len(m.references)
+
+	ref := m.references[m.selectedRef]
+	m.refreshContent()
+
+	if ref.line < m.viewport.YOffset || ref.line >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(ref.line - m.viewport.Height/2)
+	}
+}
+
+func (m *model) followReference() {
+	if m.selectedRef == -1 {
+		return
+	}
+
+	target := m.references[m.selectedRef].target
+
+	for index, item := range m.items {
+		if item.namespace != target.Namespace || item.protocol.Name != target.Protocol {
+			continue
+		}
+
+		m.history = append(m.history, pagerLocation{
+			itemIndex:   m.selectedItemIndex,
+			yOffset:     m.viewport.YOffset,
+			selectedRef: m.selectedRef,
+		})
+
+		if index != m.selectedItemIndex {
+			m.openItem(index, 0)
+		} else {
+			m.selectedRef = -1
+			m.refreshContent()
+		}
+
+		m.viewport.SetYOffset(m.layout.Line(definitionElement(target)))
+		return
+	}
+}
+
+func (m *model) goBack() {
+	if len(m.history) == 0 {
+		return
+	}
+
+	location := m.history[len(m.history)-1]
+	m.history = m.history[:len(m.history)-1]
+
+	if location.itemIndex != m.selectedItemIndex {
+		m.openItem(location.itemIndex, location.yOffset)
+	}
+
+	m.selectedRef = location.selectedRef
+	m.refreshContent()
+	m.viewport.SetYOffset(location.yOffset)
 }
 
 func (m model) footerView() string {
+	if m.pagerPrompting {
+		return m.pagerInput.View()
+	}
+
 	info := infoStyle.Render(fmt.Sprintf("%3.f%%", m.viewport.ScrollPercent()*100))
+	if matchInfo := m.matchInfo(); matchInfo != "" {
+		info = infoStyle.Render(matchInfo) + info
+	}
+
 	var selectedTitle string
 	if m.selectedItemIndex != -1 {
 		selectedTitle = fmt.Sprintf("%s ", m.items[m.selectedItemIndex].protocol.Name)
@@ -151,6 +370,11 @@ func (m model) footerView() string {
 		selectedTitle = ""
 	}
 
+	if m.selectedRef != -1 {
+		target := m.references[m.selectedRef].target
+		selectedTitle += fmt.Sprintf("→ %s (%s/%s) ", target, target.Namespace, target.Protocol)
+	}
+
 	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(info)-lipgloss.Width(selectedTitle)))
 
 	return lipgloss.JoinHorizontal(lipgloss.Center, selectedTitle, line, info)
@@ -175,7 +399,26 @@ func (m model) View() string {
 		}
 
 	case listView:
-		v = docStyle.Render(m.list.View())
+		if m.banner != "" {
+			v = docStyle.Render(m.banner + "\n" + m.list.View())
+		} else {
+			v = docStyle.Render(m.list.View())
+		}
+
+	case searchView:
+		v = m.searchViewString()
+
+	case outlineView:
+		v = m.outlineViewString()
+
+	case traceView:
+		v = docStyle.Render(m.traceList.View())
+
+	case diffView:
+		v = docStyle.Render(m.diffList.View())
+
+	case diffDetailView:
+		v = m.diffDetailViewString()
 	}
 
 	return v
@@ -187,51 +430,72 @@ func listShortHelpCallback() []key.Binding {
 			key.WithKeys("enter"),
 			key.WithHelp("enter", "select"),
 		),
+		key.NewBinding(
+			key.WithKeys("ctrl+f"),
+			key.WithHelp("ctrl+f", "search all"),
+		),
+		key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "outline"),
+		),
 	}
 }
 
-func Run(protocolToOpen string, protocols map[string][]xmlparser.Protocol) error {
+// orderNamespaces returns the namespaces of protocols that have protocols,
+// those of order first and the others sorted by name.
+func orderNamespaces(protocols map[string][]xmlparser.Protocol, order []string) []string {
+	var namespaces []string
+	for _, namespace := range order {
+		if len(protocols[namespace]) > 0 && !slices.Contains(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	var others []string
+	for namespace, group := range protocols {
+		if len(group) > 0 && !slices.Contains(namespaces, namespace) {
+			others = append(others, namespace)
+		}
+	}
+	sort.Strings(others)
+
+	return append(namespaces, others...)
+}
+
+func newModel(protocolToOpen string, protocols map[string][]xmlparser.Protocol, revisions map[string]Revision, failed []string, incomplete []string, order []string) model {
 	var items []list.Item
 	var mItems []item
 	selectedIndex := -1
 
-	{
-		namespaces := []string{
-			"core",
-			"stable",
-			"staging",
-			"unstable",
-			"wlroots",
-			"weston",
-			"kde",
-		}
-		count := 0
-		for _, namespace := range namespaces {
-			sort.Slice(protocols[namespace], func(i, j int) bool {
-				a := protocols[namespace][i]
-				b := protocols[namespace][j]
-
-				return a.Name < b.Name
-			})
-
-			for _, protocol := range protocols[namespace] {
-				if protocol.Name == protocolToOpen {
-					selectedIndex = count
-				}
+	for _, namespace := range orderNamespaces(protocols, order) {
+		sort.Slice(protocols[namespace], func(i, j int) bool {
+			a := protocols[namespace][i]
+			b := protocols[namespace][j]
+
+			return a.Name < b.Name
+		})
 
-				item := newItem(protocol, namespace)
-				items = append(items, item)
-				mItems = append(mItems, item)
+		items = append(items, header{
+			namespace: namespace,
+			count:     len(protocols[namespace]),
+			revision:  revisions[namespace],
+		})
 
-				count++
+		for _, protocol := range protocols[namespace] {
+			// protocols added by the user win over those of other namespaces
+			if protocol.Name == protocolToOpen && (selectedIndex == -1 || namespace == additionsNamespace) {
+				selectedIndex = len(mItems)
 			}
+
+			item := newItem(protocol, namespace)
+			items = append(items, item)
+			mItems = append(mItems, item)
 		}
 	}
 
 	var currentView view
-	if protocolToOpen == "" {
+	if selectedIndex == -1 {
 		currentView = listView
-		selectedIndex = -1
 	} else {
 		currentView = pagerView
 	}
@@ -240,16 +504,44 @@ func Run(protocolToOpen string, protocols map[string][]xmlparser.Protocol) error
 	defaultDelegate.ShortHelpFunc = listShortHelpCallback
 
 	m := model{
-		list:              list.New(items, defaultDelegate, 0, 0),
+		list:              list.New(items, listDelegate{defaultDelegate}, 0, 0),
 		current:           currentView,
 		items:             mItems,
 		selectedItemIndex: selectedIndex,
+		index:             resolver.New(protocols),
+		selectedRef:       -1,
+		searchIndex:       search.New(protocols),
+		searchInput:       newSearchInput(),
+		searchList:        newSearchList(),
+		pagerInput:        newPagerInput(),
+		currentMatch:      -1,
+		traceList:         newTraceList(nil),
+		diffList:          newDiffList(nil),
+		banner:            fetchBanner(failed, incomplete),
 	}
 
 	if m.current == pagerView {
 		m.pending = pagerView
 	}
 
+	// the first entry is the header of a namespace
+	if len(items) > 1 {
+		m.list.Select(1)
+	}
+
+	return m
+}
+
+// Run browses every namespace of protocols, those of order first, and opens
+// the protocol named protocolToOpen if not empty. The headers of namespaces
+// show their revision if found in revisions, and the namespaces that could
+// not be fetched, failed entirely or incomplete, are listed above them.
+func Run(protocolToOpen string, protocols map[string][]xmlparser.Protocol, revisions map[string]Revision, failed []string, incomplete []string, order []string) error {
+	m := newModel(protocolToOpen, protocols, revisions, failed, incomplete, order)
+	if protocolToOpen != "" && m.selectedItemIndex == -1 {
+		return fmt.Errorf("no protocol named %q", protocolToOpen)
+	}
+
 	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
 	if _, err := p.Run(); err != nil {
 		return err
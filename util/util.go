@@ -63,18 +63,3 @@ func RemoveAtIndex(s [][]byte, i int) [][]byte {
 	s[i] = s[len(s)-1]
 	return s[:len(s)-1]
 }
-
-func ReadAllFiles(files []string) ([][]byte, error) {
-	var contents [][]byte
-
-	for _, file := range files {
-		data, err := os.ReadFile(file)
-		if err != nil {
-			return nil, err
-		}
-
-		contents = append(contents, data)
-	}
-
-	return contents, nil
-}
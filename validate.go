@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"wlpv/cli"
+	"wlpv/validate"
+	"wlpv/xmlparser"
+)
+
+type validateResult struct {
+	File     string           `json:"file"`
+	Protocol string           `json:"protocol,omitempty"`
+	Issues   []validate.Issue `json:"issues"`
+}
+
+type validateReport struct {
+	Results  []validateResult `json:"results"`
+	Errors   int              `json:"errors"`
+	Warnings int              `json:"warnings"`
+}
+
+func readProtocolFile(path string) (xmlparser.Protocol, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return xmlparser.Protocol{}, err
+	}
+
+	return xmlparser.ParseProtocolFile(path, data)
+}
+
+func runValidate(opts cli.ValidateOptions) int {
+	var report validateReport
+	var protocols []xmlparser.Protocol
+
+	parsed := make(map[int]xmlparser.Protocol) // result index -> protocol
+
+	for i, file := range opts.Files {
+		result := validateResult{File: file, Issues: []validate.Issue{}}
+
+		protocol, err := readProtocolFile(file)
+		if err != nil {
+			result.Issues = append(result.Issues, validate.Issue{
+				Severity: validate.SeverityError,
+				Message:  err.Error(),
+			})
+		} else {
+			result.Protocol = protocol.Name
+			protocols = append(protocols, protocol)
+			parsed[i] = protocol
+		}
+
+		report.Results = append(report.Results, result)
+	}
+
+	for _, file := range opts.References {
+		protocol, err := readProtocolFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+
+		protocols = append(protocols, protocol)
+	}
+
+	validator := validate.New(protocols)
+
+	for i := range report.Results {
+		result := &report.Results[i]
+
+		if protocol, ok := parsed[i]; ok {
+			result.Issues = append(result.Issues, validator.Protocol(protocol)...)
+		}
+
+		for _, issue := range result.Issues {
+			switch issue.Severity {
+			case validate.SeverityError:
+				report.Errors++
+			case validate.SeverityWarning:
+				report.Warnings++
+			}
+		}
+	}
+
+	switch opts.Format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+
+	default:
+		for _, result := range report.Results {
+			for _, issue := range result.Issues {
+				if issue.Protocol == "" {
+					fmt.Printf("%s: %s\n", issue.Severity, issue.Message)
+				} else {
+					fmt.Printf("%s: %s\n", result.File, issue)
+				}
+			}
+		}
+
+		fmt.Printf("%d file(s) checked, %d error(s), %d warning(s)\n",
+			len(report.Results),
+			report.Errors,
+			report.Warnings,
+		)
+	}
+
+	if report.Errors > 0 || (opts.Strict && report.Warnings > 0) {
+		return 1
+	}
+
+	return 0
+}
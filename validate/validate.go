@@ -0,0 +1,356 @@
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"wlpv/xmlparser"
+)
+
+type Severity uint8
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+
+	return ""
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type Issue struct {
+	Severity Severity `json:"severity"`
+	Protocol string   `json:"protocol"`
+	Path     string   `json:"path"` // dotted element path, e.g. wl_surface.attach.buffer
+	Message  string   `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Path == "" {
+		return fmt.Sprintf("%s: %s: %s", i.Severity, i.Protocol, i.Message)
+	}
+
+	return fmt.Sprintf("%s: %s: %s: %s", i.Severity, i.Protocol, i.Path, i.Message)
+}
+
+var argTypes = map[string]bool{
+	"int":    true,
+	"uint":   true,
+	"fixed":  true,
+	"string": true,
+	"object": true,
+	"new_id": true,
+	"array":  true,
+	"fd":     true,
+}
+
+var identifierRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+var entryNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// Validator checks protocols against each other, an interface referenced by
+// name is known if it is declared in any of the protocols given to New.
+type Validator struct {
+	interfaces map[string]xmlparser.Interface
+}
+
+func New(protocols []xmlparser.Protocol) Validator {
+	v := Validator{interfaces: make(map[string]xmlparser.Interface)}
+
+	for _, protocol := range protocols {
+		for _, iface := range protocol.Interfaces {
+			if _, ok := v.interfaces[iface.Name]; !ok {
+				v.interfaces[iface.Name] = iface
+			}
+		}
+	}
+
+	return v
+}
+
+type checker struct {
+	Validator
+	protocol string
+	issues   []Issue
+}
+
+func (c *checker) errorf(path string, format string, a ...any) {
+	c.issues = append(c.issues, Issue{
+		Severity: SeverityError,
+		Protocol: c.protocol,
+		Path:     path,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+func (c *checker) warnf(path string, format string, a ...any) {
+	c.issues = append(c.issues, Issue{
+		Severity: SeverityWarning,
+		Protocol: c.protocol,
+		Path:     path,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+// Protocol returns every issue found in p, errors are violations that
+// wayland-scanner rejects or that produce broken bindings.
+func (v Validator) Protocol(p xmlparser.Protocol) []Issue {
+	c := checker{Validator: v, protocol: p.Name}
+
+	if !identifierRegexp.MatchString(p.Name) {
+		c.errorf("", "invalid protocol name %q", p.Name)
+	}
+
+	seen := make(map[string]bool)
+	for _, iface := range p.Interfaces {
+		if seen[iface.Name] {
+			c.errorf(iface.Name, "duplicate interface name")
+		}
+		seen[iface.Name] = true
+
+		c.checkInterface(iface)
+	}
+
+	return c.issues
+}
+
+func parseVersion(s string) (int, bool) {
+	version, err := strconv.Atoi(s)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+
+	return version, true
+}
+
+func (c *checker) checkSince(path string, since string, version int) int {
+	if since == "" {
+		return 1
+	}
+
+	sinceVersion, ok := parseVersion(since)
+	if !ok {
+		c.errorf(path, "invalid since version %q", since)
+		return 1
+	}
+
+	if version != 0 && sinceVersion > version {
+		c.errorf(path, "since version %d exceeds interface version %d", sinceVersion, version)
+	}
+
+	return sinceVersion
+}
+
+func (c *checker) checkInterface(iface xmlparser.Interface) {
+	if !identifierRegexp.MatchString(iface.Name) {
+		c.errorf(iface.Name, "invalid interface name")
+	}
+
+	version, ok := parseVersion(iface.Version)
+	if !ok {
+		c.errorf(iface.Name, "invalid interface version %q", iface.Version)
+	}
+
+	c.checkMessages(iface, iface.Requests, version)
+	c.checkMessages(iface, iface.Events, version)
+
+	seen := make(map[string]bool)
+	for _, enum := range iface.Enums {
+		path := iface.Name + "." + enum.Name
+
+		if seen[enum.Name] {
+			c.errorf(path, "duplicate enum name")
+		}
+		seen[enum.Name] = true
+
+		c.checkEnum(path, enum, version)
+	}
+}
+
+func (c *checker) checkMessages(iface xmlparser.Interface, messages []xmlparser.Message, version int) {
+	seen := make(map[string]bool)
+
+	for _, message := range messages {
+		path := iface.Name + "." + message.Name
+
+		if !identifierRegexp.MatchString(message.Name) {
+			c.errorf(path, "invalid %s name", message.Kind)
+		}
+
+		if seen[message.Name] {
+			c.errorf(path, "duplicate %s name", message.Kind)
+		}
+		seen[message.Name] = true
+
+		if message.Type != "" && message.Type != "destructor" {
+			c.errorf(path, "invalid %s type %q", message.Kind, message.Type)
+		}
+
+		since := c.checkSince(path, message.Since, version)
+
+		if message.DeprecatedSince != "" {
+			deprecatedSince, ok := parseVersion(message.DeprecatedSince)
+			if !ok {
+				c.errorf(path, "invalid deprecated-since version %q", message.DeprecatedSince)
+			} else if deprecatedSince < since {
+				c.errorf(path, "deprecated-since version %d is lower than since version %d", deprecatedSince, since)
+			} else if version != 0 && deprecatedSince > version {
+				c.errorf(path, "deprecated-since version %d exceeds interface version %d", deprecatedSince, version)
+			}
+		}
+
+		c.checkArguments(iface, path, message, since)
+	}
+}
+
+func (c *checker) checkArguments(iface xmlparser.Interface, path string, message xmlparser.Message, since int) {
+	seen := make(map[string]bool)
+	newIDs := 0
+
+	for _, arg := range message.Arguments {
+		argPath := path + "." + arg.Name
+
+		if !identifierRegexp.MatchString(arg.Name) {
+			c.errorf(argPath, "invalid argument name")
+		}
+
+		if seen[arg.Name] {
+			c.errorf(argPath, "duplicate argument name")
+		}
+		seen[arg.Name] = true
+
+		if !argTypes[arg.Type] {
+			c.errorf(argPath, "invalid argument type %q", arg.Type)
+			continue
+		}
+
+		if arg.Type == "new_id" {
+			newIDs++
+		}
+
+		if arg.Interface != "" {
+			if arg.Type != "object" && arg.Type != "new_id" {
+				c.errorf(argPath, "interface attribute is only valid for object and new_id arguments")
+			} else if _, ok := c.interfaces[arg.Interface]; !ok {
+				c.warnf(argPath, "unknown interface %q", arg.Interface)
+			}
+		} else if arg.Type == "new_id" && message.Kind == xmlparser.MessageKindEvent {
+			c.errorf(argPath, "new_id argument of an event must name an interface")
+		}
+
+		switch arg.AllowNull {
+		case "", "false":
+		case "true":
+			switch arg.Type {
+			case "string", "object", "new_id", "array":
+			default:
+				c.errorf(argPath, "allow-null is only valid for string, object, new_id and array arguments, not %s", arg.Type)
+			}
+		default:
+			c.errorf(argPath, "invalid allow-null value %q", arg.AllowNull)
+		}
+
+		if arg.Since != "" {
+			argSince, ok := parseVersion(arg.Since)
+			if !ok {
+				c.errorf(argPath, "invalid since version %q", arg.Since)
+			} else if argSince < since {
+				c.errorf(argPath, "since version %d is lower than the %s since version %d", argSince, message.Kind, since)
+			}
+		}
+
+		if arg.Enum != "" {
+			c.checkEnumReference(iface, argPath, arg)
+		}
+	}
+
+	if newIDs > 1 && message.Kind == xmlparser.MessageKindRequest {
+		c.errorf(path, "request has more than one new_id argument")
+	}
+}
+
+func (c *checker) checkEnumReference(iface xmlparser.Interface, path string, arg xmlparser.Argument) {
+	if arg.Type != "int" && arg.Type != "uint" {
+		c.errorf(path, "enum attribute is only valid for int and uint arguments, not %s", arg.Type)
+		return
+	}
+
+	ifaceName, enumName, found := strings.Cut(arg.Enum, ".")
+	if !found {
+		ifaceName, enumName = iface.Name, arg.Enum
+	} else {
+		referenced, ok := c.interfaces[ifaceName]
+		if !ok {
+			c.warnf(path, "enum %q references unknown interface %q", arg.Enum, ifaceName)
+			return
+		}
+		iface = referenced
+	}
+
+	for _, enum := range iface.Enums {
+		if enum.Name != enumName {
+			continue
+		}
+
+		if enum.Bitfield == "true" && arg.Type != "uint" {
+			c.errorf(path, "bitfield enum %q is only valid for uint arguments, not %s", arg.Enum, arg.Type)
+		}
+
+		return
+	}
+
+	c.errorf(path, "enum %q not found in interface %s", enumName, ifaceName)
+}
+
+func (c *checker) checkEnum(path string, enum xmlparser.Enum, version int) {
+	if !identifierRegexp.MatchString(enum.Name) {
+		c.errorf(path, "invalid enum name")
+	}
+
+	if enum.Bitfield != "" && enum.Bitfield != "true" && enum.Bitfield != "false" {
+		c.errorf(path, "invalid bitfield value %q", enum.Bitfield)
+	}
+
+	since := c.checkSince(path, enum.Since, version)
+
+	if len(enum.Entries) == 0 {
+		c.warnf(path, "enum has no entries")
+	}
+
+	names := make(map[string]bool)
+
+	for _, entry := range enum.Entries {
+		entryPath := path + "." + entry.Name
+
+		if !entryNameRegexp.MatchString(entry.Name) {
+			c.errorf(entryPath, "invalid entry name")
+		}
+
+		if names[entry.Name] {
+			c.errorf(entryPath, "duplicate entry name")
+		}
+		names[entry.Name] = true
+
+		if _, err := xmlparser.ParseValue(entry.Value); err != nil {
+			c.errorf(entryPath, "%v", err)
+		}
+
+		if entry.Since != "" {
+			entrySince := c.checkSince(entryPath, entry.Since, version)
+			if entrySince < since {
+				c.errorf(entryPath, "since version %d is lower than the enum since version %d", entrySince, since)
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const recordHeaderSize = 5
+
+// Record is a chunk of a connection as captured, bytes sent in one
+// direction. A message may be split across records of its direction.
+type Record struct {
+	Direction Direction
+	Data      []byte
+}
+
+// ReadCapture splits a capture of both directions of a connection into its
+// records. Each record is a direction byte, 0 for the client and 1 for the
+// compositor, followed by the length of the data in 32 bits of order and
+// the data.
+func ReadCapture(data []byte, order binary.ByteOrder) ([]Record, error) {
+	var records []Record
+
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < recordHeaderSize {
+			return nil, &DecodeError{Offset: offset, Err: errors.New("truncated record header")}
+		}
+
+		direction := Direction(data[offset])
+		if direction != ClientToServer && direction != ServerToClient {
+			return nil, &DecodeError{Offset: offset, Err: fmt.Errorf("invalid direction %d", direction)}
+		}
+
+		length := uint64(order.Uint32(data[offset+1:]))
+		if uint64(len(data)-offset-recordHeaderSize) < length {
+			return nil, &DecodeError{Offset: offset, Err: fmt.Errorf("record of %d bytes is truncated", length)}
+		}
+
+		start := offset + recordHeaderSize
+		records = append(records, Record{Direction: direction, Data: data[start : start+int(length)]})
+		offset = start + int(length)
+	}
+
+	return records, nil
+}
+
+// DecodeRecords decodes the messages of both directions of a connection in
+// the order they were sent, the offsets of which are in the stream of their
+// direction. On error, the messages decoded up to the offending one are
+// returned along with a *DecodeError.
+func (d *Decoder) DecodeRecords(records []Record) ([]Message, error) {
+	var (
+		messages []Message
+		streams  [2][]byte // received and not decoded yet, by direction
+		offsets  [2]int    // of the start of streams in their direction
+	)
+
+	for _, record := range records {
+		direction := record.Direction
+		streams[direction] = append(streams[direction], record.Data...)
+
+		for !d.pending(streams[direction]) {
+			message, err := d.decodeMessage(streams[direction], 0, direction)
+			if err != nil {
+				return messages, moveError(err, offsets[direction])
+			}
+
+			message.Offset = offsets[direction]
+			messages = append(messages, message)
+
+			streams[direction] = streams[direction][message.Size:]
+			offsets[direction] += message.Size
+		}
+	}
+
+	for direction, stream := range streams {
+		if len(stream) == 0 {
+			continue
+		}
+
+		// the capture ends within the message, which reports it truncated
+		_, err := d.decodeMessage(stream, 0, Direction(direction))
+		return messages, moveError(err, offsets[direction])
+	}
+
+	return messages, nil
+}
+
+// pending reports whether data is the start of a message that is not
+// received in full yet.
+func (d *Decoder) pending(data []byte) bool {
+	if len(data) < headerSize {
+		return true
+	}
+
+	size := int(d.ByteOrder.Uint32(data[4:]) >> 16)
+
+	return size >= headerSize && size%4 == 0 && len(data) < size
+}
+
+// moveError shifts the offset of a *DecodeError by offset.
+func moveError(err error, offset int) error {
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		decodeErr.Offset += offset
+	}
+
+	return err
+}
@@ -0,0 +1,350 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"wlpv/resolver"
+	"wlpv/xmlparser"
+)
+
+// Direction of a stream, requests are sent by the client and events by the
+// compositor.
+type Direction uint8
+
+const (
+	ClientToServer Direction = iota
+	ServerToClient
+)
+
+const headerSize = 8
+
+// Value is a decoded argument, the field matching the type of Argument is
+// set. Argument is made up for the interface and version that precede a
+// new_id without interface on the wire.
+type Value struct {
+	Argument  xmlparser.Argument
+	Int       int32
+	Uint      uint32
+	Fixed     float64
+	Str       string
+	Null      bool   // null string or object
+	Object    uint32 // id of an object or new_id
+	Interface string // interface of an object or new_id, if known
+	Array     []byte
+}
+
+// String returns the value as printed by WAYLAND_DEBUG.
+func (v Value) String() string {
+	switch v.Argument.Type {
+	case "int":
+		return fmt.Sprint(v.Int)
+	case "uint":
+		return fmt.Sprint(v.Uint)
+	case "fixed":
+		return fmt.Sprintf("%f", v.Fixed)
+	case "string":
+		if v.Null {
+			return "nil"
+		}
+		return fmt.Sprintf("%q", v.Str)
+	case "object":
+		if v.Null {
+			return "nil"
+		}
+		return fmt.Sprintf("%s#%d", interfaceName(v.Interface), v.Object)
+	case "new_id":
+		return fmt.Sprintf("new id %s#%d", interfaceName(v.Interface), v.Object)
+	case "array":
+		return fmt.Sprintf("array[%d]", len(v.Array))
+	case "fd":
+		return "fd"
+	}
+
+	return "?"
+}
+
+func interfaceName(name string) string {
+	if name == "" {
+		return "[unknown]"
+	}
+
+	return name
+}
+
+// Message is a decoded message. Definition is nil when the object or the
+// opcode is unknown, Data then holds the undecoded arguments.
+type Message struct {
+	Offset     int // of the header in the stream of its direction
+	Direction  Direction
+	Object     uint32
+	Interface  string // empty if the object is unknown
+	Opcode     uint16
+	Size       int // including the header
+	Definition *xmlparser.Message
+	Location   resolver.Location // interface of the object
+	Values     []Value
+	Data       []byte
+}
+
+// String returns the message in the form of WAYLAND_DEBUG.
+func (m Message) String() string {
+	name := fmt.Sprintf("[opcode %d]", m.Opcode)
+	if m.Definition != nil {
+		name = m.Definition.Name
+	}
+
+	args := make([]string, len(m.Values))
+	for i, value := range m.Values {
+		args[i] = value.String()
+	}
+
+	if m.Definition == nil && len(m.Data) > 0 {
+		args = append(args, fmt.Sprintf("[%d bytes]", len(m.Data)))
+	}
+
+	return fmt.Sprintf("%s#%d.%s(%s)", interfaceName(m.Interface), m.Object, name, strings.Join(args, ", "))
+}
+
+// DecodeError reports a stream that cannot be decoded further.
+type DecodeError struct {
+	Offset int
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Decoder decodes messages of the loaded protocols, tracking the objects
+// created by new_id arguments and destroyed by wl_display.delete_id. Both
+// directions of a connection may be decoded with the same decoder, in the
+// order the messages were sent.
+type Decoder struct {
+	ByteOrder binary.ByteOrder // of the machine the stream was captured on
+
+	index      *resolver.Index
+	interfaces map[resolver.Location]xmlparser.Interface
+	objects    map[uint32]resolver.Location
+}
+
+// NewDecoder returns a little-endian decoder that knows wl_display as
+// object 1.
+func NewDecoder(protocols map[string][]xmlparser.Protocol) *Decoder {
+	d := &Decoder{
+		ByteOrder:  binary.LittleEndian,
+		index:      resolver.New(protocols),
+		interfaces: make(map[resolver.Location]xmlparser.Interface),
+		objects:    make(map[uint32]resolver.Location),
+	}
+
+	for namespace, group := range protocols {
+		for _, protocol := range group {
+			for _, iface := range protocol.Interfaces {
+				d.interfaces[resolver.Location{
+					Namespace: namespace,
+					Protocol:  protocol.Name,
+					Interface: iface.Name,
+				}] = iface
+			}
+		}
+	}
+
+	d.SetObject(1, "wl_display")
+
+	return d
+}
+
+// SetObject adds an object to the object map, for streams captured after
+// the object was created. It reports whether the interface is known.
+func (d *Decoder) SetObject(id uint32, iface string) bool {
+	location, ok := d.index.Interface(iface, resolver.Location{})
+	if !ok {
+		location = resolver.Location{Interface: iface}
+	}
+
+	d.objects[id] = location
+
+	return ok
+}
+
+// Decode decodes every message of a stream sent in one direction. On error, the messages
+// decoded up to the offending one are returned along with a *DecodeError.
+func (d *Decoder) Decode(data []byte, direction Direction) ([]Message, error) {
+	var messages []Message
+
+	for offset := 0; offset < len(data); {
+		message, err := d.decodeMessage(data, offset, direction)
+		if err != nil {
+			return messages, err
+		}
+
+		messages = append(messages, message)
+		offset += message.Size
+	}
+
+	return messages, nil
+}
+
+func (d *Decoder) decodeMessage(data []byte, offset int, direction Direction) (Message, error) {
+	if len(data)-offset < headerSize {
+		return Message{}, &DecodeError{Offset: offset, Err: errors.New("truncated message header")}
+	}
+
+	message := Message{
+		Offset:    offset,
+		Direction: direction,
+		Object:    d.ByteOrder.Uint32(data[offset:]),
+	}
+
+	sizeOpcode := d.ByteOrder.Uint32(data[offset+4:])
+	message.Size = int(sizeOpcode >> 16)
+	message.Opcode = uint16(sizeOpcode)
+
+	if message.Size < headerSize || message.Size%4 != 0 {
+		return Message{}, &DecodeError{Offset: offset, Err: fmt.Errorf("invalid message size %d", message.Size)}
+	}
+
+	if len(data)-offset < message.Size {
+		return Message{}, &DecodeError{Offset: offset, Err: fmt.Errorf("message of %d bytes is truncated", message.Size)}
+	}
+
+	body := data[offset+headerSize : offset+message.Size]
+
+	location, ok := d.objects[message.Object]
+	if !ok {
+		message.Data = body
+		return message, nil
+	}
+
+	message.Interface = location.Interface
+	message.Location = location
+
+	iface := d.interfaces[location]
+	messages := iface.Requests
+	if direction == ServerToClient {
+		messages = iface.Events
+	}
+
+	if int(message.Opcode) >= len(messages) {
+		message.Data = body
+		return message, nil
+	}
+
+	message.Definition = &messages[message.Opcode]
+
+	values, err := d.decodeArguments(body, message)
+	if err != nil {
+		return Message{}, &DecodeError{Offset: offset, Err: fmt.Errorf("%s.%s: %w", message.Interface, message.Definition.Name, err)}
+	}
+
+	message.Values = values
+	d.track(message)
+
+	return message, nil
+}
+
+func (d *Decoder) decodeArguments(body []byte, message Message) ([]Value, error) {
+	var values []Value
+
+	for _, arg := range message.Definition.Arguments {
+		if arg.Type == "new_id" && arg.Interface == "" {
+			// the interface and version of the new object come first
+			values = append(values,
+				Value{Argument: xmlparser.Argument{Name: "interface", Type: "string"}},
+				Value{Argument: xmlparser.Argument{Name: "version", Type: "uint"}},
+			)
+		}
+
+		values = append(values, Value{Argument: arg})
+	}
+
+	for i := range values {
+		value := &values[i]
+
+		if value.Argument.Type == "fd" {
+			// file descriptors are passed out of band
+			continue
+		}
+
+		if len(body) < 4 {
+			return nil, fmt.Errorf("argument %s is truncated", value.Argument.Name)
+		}
+
+		word := d.ByteOrder.Uint32(body)
+		body = body[4:]
+
+		switch value.Argument.Type {
+		case "int":
+			value.Int = int32(word)
+		case "uint":
+			value.Uint = word
+		case "fixed":
+			value.Fixed = float64(int32(word)) / 256
+		case "object":
+			value.Object = word
+			value.Null = word == 0
+			if location, ok := d.objects[word]; ok {
+				value.Interface = location.Interface
+			} else {
+				value.Interface = value.Argument.Interface
+			}
+		case "new_id":
+			value.Object = word
+			value.Interface = value.Argument.Interface
+			if value.Interface == "" && i >= 2 {
+				value.Interface = values[i-2].Str
+			}
+		case "string", "array":
+			padded := (uint64(word) + 3) &^ 3
+			if uint64(len(body)) < padded {
+				return nil, fmt.Errorf("argument %s is truncated", value.Argument.Name)
+			}
+			length := int(word)
+
+			if value.Argument.Type == "array" {
+				value.Array = body[:length]
+			} else if length == 0 {
+				value.Null = true
+			} else {
+				// the length includes the terminating NUL
+				value.Str = string(body[:length-1])
+			}
+
+			body = body[padded:]
+		default:
+			return nil, fmt.Errorf("argument %s has unknown type %q", value.Argument.Name, value.Argument.Type)
+		}
+	}
+
+	if len(body) != 0 {
+		return nil, fmt.Errorf("%d trailing bytes", len(body))
+	}
+
+	return values, nil
+}
+
+// track updates the object map after a message.
+func (d *Decoder) track(message Message) {
+	for _, value := range message.Values {
+		if value.Argument.Type != "new_id" || value.Object == 0 {
+			continue
+		}
+
+		location, ok := d.index.Interface(value.Interface, message.Location)
+		if !ok {
+			location = resolver.Location{Interface: value.Interface}
+		}
+
+		d.objects[value.Object] = location
+	}
+
+	if message.Interface == "wl_display" && message.Definition.Name == "delete_id" && len(message.Values) == 1 {
+		delete(d.objects, message.Values[0].Uint)
+	}
+}
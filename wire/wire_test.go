@@ -0,0 +1,268 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"wlpv/xmlparser"
+)
+
+const testProtocol = `<protocol name="test">
+  <interface name="wl_display" version="1">
+    <request name="sync"><arg name="callback" type="new_id" interface="wl_callback"/></request>
+    <request name="get_registry"><arg name="registry" type="new_id" interface="wl_registry"/></request>
+    <event name="error"><arg name="object_id" type="object"/><arg name="code" type="uint"/><arg name="message" type="string"/></event>
+    <event name="delete_id"><arg name="id" type="uint"/></event>
+  </interface>
+  <interface name="wl_registry" version="1">
+    <request name="bind"><arg name="name" type="uint"/><arg name="id" type="new_id"/></request>
+    <event name="global"><arg name="name" type="uint"/><arg name="interface" type="string"/><arg name="version" type="uint"/></event>
+  </interface>
+  <interface name="wl_callback" version="1">
+    <event name="done"><arg name="callback_data" type="uint"/></event>
+  </interface>
+  <interface name="wl_compositor" version="6">
+    <request name="create_surface"><arg name="id" type="new_id" interface="wl_surface"/></request>
+  </interface>
+  <interface name="wl_surface" version="6">
+    <request name="destroy" type="destructor"/>
+    <request name="offset"><arg name="x" type="int"/><arg name="y" type="int"/></request>
+  </interface>
+  <interface name="test_object" version="1">
+    <request name="set_title"><arg name="title" type="string" allow-null="true"/></request>
+    <event name="keymap"><arg name="format" type="uint"/><arg name="fd" type="fd"/><arg name="size" type="uint"/></event>
+  </interface>
+</protocol>`
+
+func newTestDecoder(t *testing.T) *Decoder {
+	protocol, err := xmlparser.ParseProtocol([]byte(testProtocol))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewDecoder(map[string][]xmlparser.Protocol{"core": {protocol}})
+}
+
+// message encodes a message of object, with uint32, int32 and string
+// arguments.
+func message(object uint32, opcode uint16, args ...any) []byte {
+	var body []byte
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case uint32:
+			body = binary.LittleEndian.AppendUint32(body, arg)
+		case int32:
+			body = binary.LittleEndian.AppendUint32(body, uint32(arg))
+		case string:
+			body = binary.LittleEndian.AppendUint32(body, uint32(len(arg)+1))
+			body = append(body, arg...)
+			body = append(body, make([]byte, 4-len(arg)%4)...)
+		}
+	}
+
+	data := binary.LittleEndian.AppendUint32(nil, object)
+	data = binary.LittleEndian.AppendUint32(data, uint32(headerSize+len(body))<<16|uint32(opcode))
+
+	return append(data, body...)
+}
+
+func join(messages []Message) string {
+	s := make([]string, len(messages))
+	for i, message := range messages {
+		s[i] = message.String()
+	}
+
+	return strings.Join(s, "\n")
+}
+
+func TestDecodeNewID(t *testing.T) {
+	d := newTestDecoder(t)
+
+	data := append(message(1, 1, uint32(2)), message(2, 0, uint32(1), "wl_compositor", uint32(6), uint32(3))...)
+	data = append(data, message(3, 0, uint32(4))...)
+	data = append(data, message(4, 1, int32(-1), int32(2))...)
+
+	messages, err := d.Decode(data, ClientToServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `wl_display#1.get_registry(new id wl_registry#2)
+wl_registry#2.bind(1, "wl_compositor", 6, new id wl_compositor#3)
+wl_compositor#3.create_surface(new id wl_surface#4)
+wl_surface#4.offset(-1, 2)`
+
+	if got := join(messages); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// the interface and version of the untyped new_id are made up
+	bind := messages[1].Values
+	if len(bind) != 4 || bind[1].Argument.Name != "interface" || bind[2].Argument.Name != "version" || bind[3].Argument.Name != "id" {
+		t.Errorf("got bind values %+v", bind)
+	}
+}
+
+func TestDecodeDeleteID(t *testing.T) {
+	d := newTestDecoder(t)
+
+	if _, err := d.Decode(message(1, 0, uint32(5)), ClientToServer); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := d.Decode(append(message(5, 0, uint32(7)), message(1, 1, uint32(5))...), ServerToClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a deleted id is unknown until it is created again
+	after, err := d.Decode(message(5, 0, uint32(7)), ServerToClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `wl_callback#5.done(7)
+wl_display#1.delete_id(5)
+[unknown]#5.[opcode 0]([4 bytes])`
+
+	if got := join(append(messages, after...)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDecodeStrings(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+		err  string
+	}{
+		{"padded", message(3, 0, "title"), `test_object#3.set_title("title")`, ""},
+		{"no padding", message(3, 0, "abc"), `test_object#3.set_title("abc")`, ""},
+		{"empty", message(3, 0, ""), `test_object#3.set_title("")`, ""},
+		{"null", message(3, 0, uint32(0)), "test_object#3.set_title(nil)", ""},
+		// a length of 8 with 4 bytes of content
+		{"truncated", message(3, 0, uint32(8), uint32(0x61626300)), "", "offset 0: test_object.set_title: argument title is truncated"},
+		// "abcdefgh" without its NUL and padding
+		{"missing padding", message(3, 0, uint32(9), uint32(0x64636261), uint32(0x68676665)), "", "offset 0: test_object.set_title: argument title is truncated"},
+		{"trailing bytes", message(3, 0, "abc", uint32(0)), "", "offset 0: test_object.set_title: 4 trailing bytes"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := newTestDecoder(t)
+			d.SetObject(3, "test_object")
+
+			messages, err := d.Decode(test.data, ClientToServer)
+			if test.err != "" {
+				var decodeErr *DecodeError
+				if !errors.As(err, &decodeErr) || err.Error() != test.err {
+					t.Errorf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := join(messages); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSkipsFd(t *testing.T) {
+	d := newTestDecoder(t)
+	d.SetObject(3, "test_object")
+
+	// the fd is passed out of band, the size follows the format
+	messages, err := d.Decode(message(3, 0, uint32(1), uint32(4096)), ServerToClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := join(messages), "test_object#3.keymap(1, fd, 4096)"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// capture encodes records in the format read by ReadCapture.
+func capture(records ...Record) []byte {
+	var data []byte
+	for _, record := range records {
+		data = append(data, byte(record.Direction))
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(record.Data)))
+		data = append(data, record.Data...)
+	}
+
+	return data
+}
+
+func TestDecodeRecords(t *testing.T) {
+	getRegistry := message(1, 1, uint32(2))
+	global := message(2, 0, uint32(1), "wl_compositor", uint32(6))
+	bind := message(2, 0, uint32(1), "wl_compositor", uint32(6), uint32(3))
+
+	data := capture(
+		Record{ClientToServer, getRegistry},
+		// a read boundary within the event
+		Record{ServerToClient, global[:10]},
+		Record{ServerToClient, global[10:]},
+		Record{ClientToServer, append(bind, message(3, 0, uint32(4))...)},
+		Record{ServerToClient, message(1, 0, uint32(4), uint32(0), "invalid")},
+	)
+
+	d := newTestDecoder(t)
+
+	records, err := ReadCapture(data, d.ByteOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := d.DecodeRecords(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `wl_display#1.get_registry(new id wl_registry#2)
+wl_registry#2.global(1, "wl_compositor", 6)
+wl_registry#2.bind(1, "wl_compositor", 6, new id wl_compositor#3)
+wl_compositor#3.create_surface(new id wl_surface#4)
+wl_display#1.error(wl_surface#4, 0, "invalid")`
+
+	if got := join(messages); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	directions := []Direction{ClientToServer, ServerToClient, ClientToServer, ClientToServer, ServerToClient}
+	offsets := []int{0, 0, len(getRegistry), len(getRegistry) + len(bind), len(global)}
+	for i, message := range messages {
+		if message.Direction != directions[i] || message.Offset != offsets[i] {
+			t.Errorf("message %d: got direction %v at %d, want %v at %d", i, message.Direction, message.Offset, directions[i], offsets[i])
+		}
+	}
+}
+
+func TestDecodeRecordsErrors(t *testing.T) {
+	d := newTestDecoder(t)
+
+	if _, err := ReadCapture(capture(Record{ClientToServer, message(1, 0, uint32(2))})[:10], d.ByteOrder); err == nil || err.Error() != "offset 0: record of 12 bytes is truncated" {
+		t.Errorf("got error %v for a truncated record", err)
+	}
+
+	if _, err := ReadCapture([]byte{2, 0, 0, 0, 0}, d.ByteOrder); err == nil || err.Error() != "offset 0: invalid direction 2" {
+		t.Errorf("got error %v for an invalid direction", err)
+	}
+
+	// the capture ends within the second request
+	first := message(1, 0, uint32(2))
+	records := []Record{{ClientToServer, append(first, message(1, 0, uint32(3))[:8]...)}}
+
+	messages, err := d.DecodeRecords(records)
+	if len(messages) != 1 || err == nil || err.Error() != "offset 12: message of 12 bytes is truncated" {
+		t.Errorf("got %d messages and error %v, want the first one and the truncated second one", len(messages), err)
+	}
+}
@@ -0,0 +1,106 @@
+package xmlparser
+
+import "strings"
+
+type ElementKind uint8
+
+const (
+	ElementProtocol ElementKind = iota
+	ElementInterface
+	ElementRequest
+	ElementEvent
+	ElementEnum
+	ElementRequestArgument
+	ElementEventArgument
+	ElementEntry
+	ElementCopyright
+)
+
+func (k ElementKind) String() string {
+	switch k {
+	case ElementProtocol:
+		return "protocol"
+	case ElementInterface:
+		return "interface"
+	case ElementRequest:
+		return "request"
+	case ElementEvent:
+		return "event"
+	case ElementEnum:
+		return "enum"
+	case ElementRequestArgument, ElementEventArgument:
+		return "arg"
+	case ElementEntry:
+		return "entry"
+	case ElementCopyright:
+		return "copyright"
+	}
+
+	return ""
+}
+
+// Element identifies a part of a protocol. Member is the name of a request,
+// event or enum and Child that of an argument or entry, fields that do not
+// apply to the kind of element are empty.
+type Element struct {
+	Kind      ElementKind
+	Interface string
+	Member    string
+	Child     string
+}
+
+// Path returns the dotted name of the element within its protocol.
+func (e Element) Path() string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{e.Interface, e.Member, e.Child} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// Layout maps the elements of a rendered protocol to their line numbers.
+type Layout map[Element]int
+
+// Line returns the line of the element, or of its closest enclosing element
+// if the element itself is not part of the layout.
+func (l Layout) Line(e Element) int {
+	if line, ok := l[e]; ok {
+		return line
+	}
+
+	switch e.Kind {
+	case ElementRequestArgument:
+		return l.Line(Element{Kind: ElementRequest, Interface: e.Interface, Member: e.Member})
+	case ElementEventArgument:
+		return l.Line(Element{Kind: ElementEvent, Interface: e.Interface, Member: e.Member})
+	case ElementEntry:
+		return l.Line(Element{Kind: ElementEnum, Interface: e.Interface, Member: e.Member})
+	case ElementRequest, ElementEvent, ElementEnum:
+		return l.Line(Element{Kind: ElementInterface, Interface: e.Interface})
+	}
+
+	return 0
+}
+
+type renderer struct {
+	strings.Builder
+	counted int // bytes of the builder whose newlines are counted in lines
+	lines   int
+	layout  Layout
+}
+
+// line returns the line the next write starts on.
+func (r *renderer) line() int {
+	content := r.String()
+	r.lines += strings.Count(content[r.counted:], "\n")
+	r.counted = len(content)
+
+	return r.lines
+}
+
+func (r *renderer) mark(e Element) {
+	r.layout[e] = r.line()
+}
@@ -1,8 +1,12 @@
 package xmlparser
 
 import (
+	"bytes"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 )
@@ -12,38 +16,59 @@ type Protocol struct {
 	Name        string      `xml:"name,attr"`
 	Copyright   string      `xml:"copyright"`
 	Description Description `xml:"description"`
-	Interfaces  []struct {
-		XMLName     xml.Name    `xml:"interface"`
-		Name        string      `xml:"name,attr"`
-		Version     string      `xml:"version,attr"`
-		Description Description `xml:"description"`
-		Requests    []struct {
-			XMLName     xml.Name    `xml:"request"`
-			Name        string      `xml:"name,attr"`
-			Type        string      `xml:"type,attr"`
-			Since       string      `xml:"since,attr"`
-			Description Description `xml:"description"`
-			Arguments   []Argument  `xml:"arg"`
-		} `xml:"request"`
-		Events []struct {
-			XMLName         xml.Name    `xml:"event"`
-			Name            string      `xml:"name,attr"`
-			Type            string      `xml:"type,attr"`
-			Since           string      `xml:"since,attr"`
-			DeprecatedSince string      `xml:"deprecated-since,attr"`
-			Description     Description `xml:"description"`
-			Arguments       []Argument  `xml:"arg"`
-		} `xml:"event"`
-		Enums []struct {
-			XMLName     xml.Name    `xml:"enum"`
-			Name        string      `xml:"name,attr"`
-			Bitfield    string      `xml:"bitfield,attr"`
-			Since       string      `xml:"since,attr"`
-			Description Description `xml:"description"`
-
-			Entries []Entry `xml:"entry"`
-		} `xml:"enum"`
-	} `xml:"interface"`
+	Interfaces  []Interface `xml:"interface"`
+}
+
+type Interface struct {
+	XMLName     xml.Name    `xml:"interface"`
+	Name        string      `xml:"name,attr"`
+	Version     string      `xml:"version,attr"`
+	Description Description `xml:"description"`
+	Requests    []Message   `xml:"request"`
+	Events      []Message   `xml:"event"`
+	Enums       []Enum      `xml:"enum"`
+}
+
+type MessageKind uint8
+
+const (
+	MessageKindRequest MessageKind = iota
+	MessageKindEvent
+)
+
+func (k MessageKind) String() string {
+	switch k {
+	case MessageKindRequest:
+		return "request"
+	case MessageKindEvent:
+		return "event"
+	}
+
+	return ""
+}
+
+// Message is either a request or an event, Kind tells which. Opcode is
+// the index of the message among the requests or the events of its
+// interface, which identifies it on the wire.
+type Message struct {
+	XMLName         xml.Name    // "request" or "event"
+	Kind            MessageKind `xml:"-"`
+	Opcode          int         `xml:"-"`
+	Name            string      `xml:"name,attr"`
+	Type            string      `xml:"type,attr"`
+	Since           string      `xml:"since,attr"`
+	DeprecatedSince string      `xml:"deprecated-since,attr"`
+	Description     Description `xml:"description"`
+	Arguments       []Argument  `xml:"arg"`
+}
+
+type Enum struct {
+	XMLName     xml.Name    `xml:"enum"`
+	Name        string      `xml:"name,attr"`
+	Bitfield    string      `xml:"bitfield,attr"`
+	Since       string      `xml:"since,attr"`
+	Description Description `xml:"description"`
+	Entries     []Entry     `xml:"entry"`
 }
 
 type Description struct {
@@ -64,24 +89,66 @@ type Argument struct {
 	Description Description `xml:"description"`
 }
 
+// Entry is an enum entry, Value is the literal of the protocol file and
+// Number its integer value, NumberValid is false if it is not a valid value.
 type Entry struct {
 	XMLName     xml.Name    `xml:"entry"`
 	Name        string      `xml:"name,attr"`
 	Value       string      `xml:"value,attr"`
+	Number      uint32      `xml:"-"`
+	NumberValid bool        `xml:"-"`
 	Summary     string      `xml:"summary,attr"`
 	Since       string      `xml:"since,attr"`
 	Description Description `xml:"description"`
 }
 
-func (a Argument) render() string {
+// ParseValue parses an enum entry value the way wayland-scanner does:
+// decimal, 0x-prefixed hexadecimal or 0-prefixed octal, fitting in 32 bits.
+func ParseValue(s string) (uint32, error) {
+	// unlike strtol, ParseInt also reads 0b and 0o prefixes and _ separators
+	digits := strings.TrimLeft(s, "+-")
+	if strings.Contains(s, "_") || len(digits) > 1 && digits[0] == '0' && strings.ContainsRune("bBoO", rune(digits[1])) {
+		return 0, fmt.Errorf("invalid integer value %q", s)
+	}
+
+	value, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer value %q", s)
+	}
+
+	if value < -(1<<31) || value > 1<<32-1 {
+		return 0, fmt.Errorf("value %q does not fit in 32 bits", s)
+	}
+
+	return uint32(value), nil
+}
+
+// FormatValue returns the value of the entry in decimal, followed by the
+// literal of the protocol file if it is written differently, like "8 (0x8)".
+func (e Entry) FormatValue() string {
+	if !e.NumberValid {
+		return e.Value
+	}
+
+	number := strconv.FormatUint(uint64(e.Number), 10)
+	if number == e.Value {
+		return number
+	}
+
+	return fmt.Sprintf("%s (%s)", number, e.Value)
+}
+
+// TypeName returns the type of the argument as shown in signatures, like
+// ?object<wl_buffer> for a nullable wl_buffer.
+func (a Argument) TypeName() string {
 	var argSb strings.Builder
 
 	if a.AllowNull == "true" {
-		argSb.WriteString(fmt.Sprintf("%s: ?%s", a.Name, a.Type))
-	} else {
-		argSb.WriteString(fmt.Sprintf("%s: %s", a.Name, a.Type))
+		argSb.WriteByte('?')
 	}
 
+	argSb.WriteString(a.Type)
+
 	if a.Interface != "" {
 		argSb.WriteString(fmt.Sprintf("<%s>", a.Interface))
 	} else if a.Enum != "" {
@@ -91,6 +158,10 @@ func (a Argument) render() string {
 	return argSb.String()
 }
 
+func (a Argument) render() string {
+	return fmt.Sprintf("%s: %s", a.Name, a.TypeName())
+}
+
 func renderArgumentSignature(sb *strings.Builder, args []Argument) {
 	arglen := len(args)
 	if arglen > 1 {
@@ -224,7 +295,7 @@ func renderEntryList(sb *strings.Builder, entries []Entry) {
 			fmt.Fprintf(w, " (since version: %s)", entry.Since)
 		}
 
-		fmt.Fprintf(w, "\t%s", entry.Value)
+		fmt.Fprintf(w, "\t%s", entry.FormatValue())
 
 		if entry.Summary != "" {
 			fmt.Fprintf(w, "\t'%s'", entry.Summary)
@@ -255,85 +326,260 @@ func (d Description) render(sb *strings.Builder) {
 	}
 }
 
+func (m Message) render(r *renderer, interfaceName string) {
+	kind := ElementRequest
+	argKind := ElementRequestArgument
+	if m.Kind == MessageKindEvent {
+		kind = ElementEvent
+		argKind = ElementEventArgument
+	}
+
+	r.mark(Element{Kind: kind, Interface: interfaceName, Member: m.Name})
+
+	r.WriteString(fmt.Sprintf("%s (opcode %d): %s.%s", m.Kind, m.Opcode, interfaceName, m.Name))
+
+	if m.Type != "" {
+		r.WriteString(fmt.Sprintf(" type: %s", m.Type))
+	}
+
+	if m.Since != "" {
+		r.WriteString(fmt.Sprintf(" since: version %s", m.Since))
+	}
+
+	if m.DeprecatedSince != "" {
+		r.WriteString(fmt.Sprintf(" deprecated-since: version %s", m.DeprecatedSince))
+	}
+
+	if len(m.Arguments) > 0 {
+		renderArgumentSignature(&r.Builder, m.Arguments)
+
+		// the list starts with an empty line and a header line
+		listLine := r.line() + 2
+		for i, arg := range m.Arguments {
+			r.layout[Element{
+				Kind:      argKind,
+				Interface: interfaceName,
+				Member:    m.Name,
+				Child:     arg.Name,
+			}] = listLine + i
+		}
+
+		renderArgumentList(&r.Builder, m.Arguments)
+	} else {
+		r.WriteByte('\n')
+	}
+
+	m.Description.render(&r.Builder)
+}
+
+func (e Enum) render(r *renderer, interfaceName string) {
+	r.mark(Element{Kind: ElementEnum, Interface: interfaceName, Member: e.Name})
+
+	r.WriteString(fmt.Sprintf("enum: %s.%s", interfaceName, e.Name))
+
+	if e.Bitfield == "true" {
+		r.WriteString(" (bitfield)")
+	}
+	if e.Since != "" {
+		r.WriteString(fmt.Sprintf(" (since version: %s)", e.Since))
+	}
+
+	r.WriteByte('\n')
+
+	listLine := r.line() + 2
+	for i, entry := range e.Entries {
+		r.layout[Element{
+			Kind:      ElementEntry,
+			Interface: interfaceName,
+			Member:    e.Name,
+			Child:     entry.Name,
+		}] = listLine + i
+	}
+
+	renderEntryList(&r.Builder, e.Entries)
+
+	e.Description.render(&r.Builder)
+}
+
+func (i Interface) render(r *renderer) {
+	r.mark(Element{Kind: ElementInterface, Interface: i.Name})
+
+	r.WriteString(fmt.Sprintf("interface: %s version: %s\n", i.Name, i.Version))
+	i.Description.render(&r.Builder)
+
+	for _, request := range i.Requests {
+		request.render(r, i.Name)
+	}
+
+	for _, event := range i.Events {
+		event.render(r, i.Name)
+	}
+
+	for _, enum := range i.Enums {
+		enum.render(r, i.Name)
+	}
+}
+
+// Text returns the content of the description with the indentation of
+// each line removed, paragraphs stay separated by an empty line.
+func (d Description) Text() string {
+	lines := strings.Split(strings.TrimSpace(d.Content), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
 func (p Protocol) Render() string {
-	var sb strings.Builder
+	content, _ := p.RenderLayout()
+	return content
+}
+
+// RenderLayout is like Render, but also returns the line of the rendered
+// text at which each element of the protocol starts.
+func (p Protocol) RenderLayout() (string, Layout) {
+	r := renderer{layout: make(Layout)}
 
-	sb.WriteString(fmt.Sprintf("%s\n\n", p.Name))
+	r.mark(Element{Kind: ElementProtocol})
 
-	p.Description.render(&sb)
+	r.WriteString(fmt.Sprintf("%s\n\n", p.Name))
+
+	p.Description.render(&r.Builder)
 
 	for _, iface := range p.Interfaces {
-		sb.WriteString(fmt.Sprintf("interface: %s version: %s\n", iface.Name, iface.Version))
-		iface.Description.render(&sb)
+		iface.render(&r)
+	}
 
-		for _, request := range iface.Requests {
-			sb.WriteString(fmt.Sprintf("request: %s.%s", iface.Name, request.Name))
+	r.mark(Element{Kind: ElementCopyright})
 
-			if request.Type != "" {
-				sb.WriteString(fmt.Sprintf(" type: %s", request.Type))
-			}
+	r.WriteString("copyright:\n")
+	r.WriteString(p.Copyright)
 
-			if request.Since != "" {
-				sb.WriteString(fmt.Sprintf(" since: version %s", request.Since))
-			}
+	return r.String(), r.layout
+}
 
-			if len(request.Arguments) > 0 {
-				renderArgumentSignature(&sb, request.Arguments)
-				renderArgumentList(&sb, request.Arguments)
-			}
+// Messages returns the requests followed by the events of the interface.
+func (i Interface) Messages() []Message {
+	messages := make([]Message, 0, len(i.Requests)+len(i.Events))
+	messages = append(messages, i.Requests...)
+	messages = append(messages, i.Events...)
+	return messages
+}
+
+// setComputedFields fills in the fields of the model that are not
+// attributes of the XML: message kinds and opcodes, and entry numbers.
+func (p *Protocol) setComputedFields() {
+	for i := range p.Interfaces {
+		iface := &p.Interfaces[i]
 
-			request.Description.render(&sb)
+		for j := range iface.Requests {
+			iface.Requests[j].Kind = MessageKindRequest
+			iface.Requests[j].Opcode = j
 		}
 
-		for _, event := range iface.Events {
-			sb.WriteString(fmt.Sprintf("event: %s.%s", iface.Name, event.Name))
+		for j := range iface.Events {
+			iface.Events[j].Kind = MessageKindEvent
+			iface.Events[j].Opcode = j
+		}
 
-			if event.Type != "" {
-				sb.WriteString(fmt.Sprintf(" type: %s", event.Type))
+		for j := range iface.Enums {
+			entries := iface.Enums[j].Entries
+			for k := range entries {
+				number, err := ParseValue(entries[k].Value)
+				entries[k].Number = number
+				entries[k].NumberValid = err == nil
 			}
+		}
+	}
+}
 
-			if event.Since != "" {
-				sb.WriteString(fmt.Sprintf(" since: version %s", event.Since))
-			}
+// ParseError reports malformed protocol XML, File is empty when the data
+// was not read from a file.
+type ParseError struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
 
-			if event.DeprecatedSince != "" {
-				sb.WriteString(fmt.Sprintf(" deprecated-since: version %s", event.DeprecatedSince))
-			}
+func (e *ParseError) Error() string {
+	file := e.File
+	if file == "" {
+		file = "<input>"
+	}
 
-			if len(event.Arguments) > 0 {
-				renderArgumentSignature(&sb, event.Arguments)
-				renderArgumentList(&sb, event.Arguments)
-			}
+	return fmt.Sprintf("%s:%d:%d: %v", file, e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
 
-			event.Description.render(&sb)
+func newParseError(file string, data []byte, offset int64, err error) *ParseError {
+	line, column := 1, 1
+	for _, c := range data[:min(int(offset), len(data))] {
+		if c == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
 		}
+	}
 
-		for _, enum := range iface.Enums {
-			sb.WriteString(fmt.Sprintf("enum: %s.%s", iface.Name, enum.Name))
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		err = errors.New(syntaxErr.Msg)
+	}
 
-			if enum.Bitfield == "true" {
-				sb.WriteString(" (bitfield)")
-			}
-			if enum.Since != "" {
-				sb.WriteString(fmt.Sprintf(" (since version: %s)", enum.Since))
-			}
+	return &ParseError{File: file, Line: line, Column: column, Err: err}
+}
+
+func ParseProtocol(data []byte) (Protocol, error) {
+	return ParseProtocolFile("", data)
+}
+
+// ParseProtocolFile is like ParseProtocol, but names the file in errors.
+func ParseProtocolFile(file string, data []byte) (Protocol, error) {
+	var protocol Protocol
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
 
-			sb.WriteByte('\n')
+	var (
+		root       xml.StartElement
+		rootOffset int64
+	)
+	for {
+		rootOffset = decoder.InputOffset()
 
-			renderEntryList(&sb, enum.Entries)
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return Protocol{}, newParseError(file, data, rootOffset, errors.New("no protocol element found"))
+		}
+		if err != nil {
+			return Protocol{}, newParseError(file, data, decoder.InputOffset(), err)
+		}
 
-			enum.Description.render(&sb)
+		if start, ok := token.(xml.StartElement); ok {
+			root = start
+			break
 		}
 	}
 
-	sb.WriteString("copyright:\n")
-	sb.WriteString(p.Copyright)
+	if root.Name.Local != "protocol" {
+		err := fmt.Errorf("expected <protocol> element but found <%s>", root.Name.Local)
+		return Protocol{}, newParseError(file, data, rootOffset, err)
+	}
 
-	return sb.String()
-}
+	if err := decoder.DecodeElement(&protocol, &root); err != nil {
+		return Protocol{}, newParseError(file, data, decoder.InputOffset(), err)
+	}
 
-func ParseProtocol(p []byte) Protocol {
-	var protocol Protocol
-	xml.Unmarshal(p, &protocol)
-	return protocol
+	if protocol.Name == "" {
+		return Protocol{}, newParseError(file, data, rootOffset, errors.New("protocol has no name attribute"))
+	}
+
+	protocol.setComputedFields()
+
+	return protocol, nil
 }